@@ -0,0 +1,302 @@
+package main
+
+import (
+    "bufio"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "os"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+
+    "golang.org/x/crypto/bcrypt"
+)
+
+// **Account Errors**
+// Returned by the player store and the account handlers.
+var (
+    ErrAccountExists      = errors.New("account already exists")
+    ErrInvalidAccount     = errors.New("invalid account")
+    ErrAccountNotFound    = errors.New("account not found")
+    ErrInvalidCredentials = errors.New("invalid player id or password")
+    ErrInvalidToken       = errors.New("invalid or expired session token")
+    ErrPlayerClaimed      = errors.New("player id belongs to a saved player without an account")
+)
+
+// **Player ID Pattern**
+// Allowed player IDs, which double as account names.
+var playerIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// **Password Length**
+// bcrypt only hashes the first 72 bytes, longer passwords are refused
+// rather than cut short.
+const (
+    minPasswordLength = 8
+    maxPasswordLength = 72
+)
+
+// **Token Secret**
+// Key used to sign session tokens, set from the config at startup.
+var tokenSecret []byte
+
+// **Init Auth**
+// Sets up token signing. Without a configured secret a random one is used,
+// so tokens stop working when the server restarts.
+func initAuth(c AuthConfig) error {
+    if c.TokenSecret != "" {
+        tokenSecret = []byte(c.TokenSecret)
+        return nil
+    }
+    tokenSecret = make([]byte, 32)
+    if _, err := rand.Read(tokenSecret); err != nil {
+        return fmt.Errorf("failed to generate token secret: %v", err)
+    }
+    WarningLogger.Println("No auth.tokenSecret configured, session tokens will not survive a restart")
+    return nil
+}
+
+// **Register Account**
+// Creates an account for a new player. IDs of players saved from before
+// accounts can only be registered while `auth.claimSavedPlayers` is on, since
+// IDs are shown to everyone in view and anyone could claim them. Otherwise an
+// operator gives them an account with `account create`.
+func registerAccount(playerID, password string) error {
+    if err := validateAccount(playerID, password); err != nil {
+        return err
+    }
+    _, err := store.LoadPlayer(playerID)
+    saved := err == nil
+    if err != nil && !errors.Is(err, ErrPlayerNotFound) {
+        return err
+    }
+    if saved && !config.Auth.ClaimSavedPlayers {
+        return ErrPlayerClaimed
+    }
+    if err := createAccount(playerID, password); err != nil {
+        return err
+    }
+    if saved {
+        InfoLogger.Printf("Saved player %s claimed by registration", playerID)
+    }
+    return nil
+}
+
+// **Validate Account**
+// Checks the player ID and password rules.
+func validateAccount(playerID, password string) error {
+    if !playerIDPattern.MatchString(playerID) {
+        return fmt.Errorf("%w: player id must be 1-64 letters, digits, '-' or '_'", ErrInvalidAccount)
+    }
+    if len(password) < minPasswordLength {
+        return fmt.Errorf("%w: password must be at least %d characters", ErrInvalidAccount, minPasswordLength)
+    }
+    if len(password) > maxPasswordLength {
+        return fmt.Errorf("%w: password must be at most %d bytes", ErrInvalidAccount, maxPasswordLength)
+    }
+    return nil
+}
+
+// **Create Account**
+// Stores an account with a bcrypt hashed password.
+func createAccount(playerID, password string) error {
+    if err := validateAccount(playerID, password); err != nil {
+        return err
+    }
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        return fmt.Errorf("failed to hash password: %v", err)
+    }
+    return store.CreateAccount(playerID, string(hash))
+}
+
+// **Login**
+// Checks the password and issues a session token.
+func login(playerID, password string) (string, time.Time, error) {
+    hash, err := store.LoadAccount(playerID)
+    if errors.Is(err, ErrAccountNotFound) {
+        return "", time.Time{}, ErrInvalidCredentials
+    }
+    if err != nil {
+        return "", time.Time{}, err
+    }
+    if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+        return "", time.Time{}, ErrInvalidCredentials
+    }
+    expiresAt := time.Now().Add(config.Auth.TokenTTL.Duration)
+    return issueToken(playerID, expiresAt), expiresAt, nil
+}
+
+// **Issue Token**
+// Creates a signed token of the form `base64(playerID).expiry.signature`.
+func issueToken(playerID string, expiresAt time.Time) string {
+    payload := base64.RawURLEncoding.EncodeToString([]byte(playerID)) + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+    return payload + "." + signToken(payload)
+}
+
+// **Sign Token**
+// HMAC-SHA256 signature of a token payload.
+func signToken(payload string) string {
+    mac := hmac.New(sha256.New, tokenSecret)
+    mac.Write([]byte(payload))
+    return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// **Verify Token**
+// Checks the signature and expiry and returns the player ID the token was issued to.
+func verifyToken(token string) (string, error) {
+    parts := strings.Split(token, ".")
+    if len(parts) != 3 {
+        return "", ErrInvalidToken
+    }
+    payload := parts[0] + "." + parts[1]
+    if !hmac.Equal([]byte(signToken(payload)), []byte(parts[2])) {
+        return "", ErrInvalidToken
+    }
+    expiry, err := strconv.ParseInt(parts[1], 10, 64)
+    if err != nil || time.Now().Unix() >= expiry {
+        return "", ErrInvalidToken
+    }
+    playerID, err := base64.RawURLEncoding.DecodeString(parts[0])
+    if err != nil {
+        return "", ErrInvalidToken
+    }
+    return string(playerID), nil
+}
+
+// **Credentials Structure**
+// Body of `/register` and `/login` requests.
+type Credentials struct {
+    PlayerID string `json:"playerId"`
+    Password string `json:"password"`
+}
+
+// **Handle Register**
+// POST /register creates an account.
+func handleRegister(w http.ResponseWriter, r *http.Request) {
+    creds, ok := readCredentials(w, r)
+    if !ok {
+        return
+    }
+    err := registerAccount(creds.PlayerID, creds.Password)
+    switch {
+    case errors.Is(err, ErrAccountExists), errors.Is(err, ErrPlayerClaimed):
+        writeJSONResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+    case errors.Is(err, ErrInvalidAccount):
+        writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+    case err != nil:
+        ErrorLogger.Printf("Failed to register %s: %v", creds.PlayerID, err)
+        writeJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "registration failed"})
+    default:
+        InfoLogger.Printf("Registered account %s", creds.PlayerID)
+        writeJSONResponse(w, http.StatusCreated, map[string]string{"playerId": creds.PlayerID})
+    }
+}
+
+// **Handle Login**
+// POST /login returns a session token for the `join` handshake.
+func handleLogin(w http.ResponseWriter, r *http.Request) {
+    creds, ok := readCredentials(w, r)
+    if !ok {
+        return
+    }
+    token, expiresAt, err := login(creds.PlayerID, creds.Password)
+    switch {
+    case errors.Is(err, ErrInvalidCredentials):
+        writeJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+    case err != nil:
+        ErrorLogger.Printf("Failed to log in %s: %v", creds.PlayerID, err)
+        writeJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "login failed"})
+    default:
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+            "playerId":  creds.PlayerID,
+            "token":     token,
+            "expiresAt": expiresAt.Unix(),
+        })
+    }
+}
+
+// **Run Account Command**
+// `fishserver [flags] account create <playerId>` creates an account with the
+// password read from stdin. This is how players saved before accounts existed
+// get theirs, since `/register` refuses their IDs.
+func runAccountCommand(cfg Config, args []string) error {
+    if len(args) != 2 || args[0] != "create" {
+        return fmt.Errorf("usage: fishserver [flags] account create <playerId> < password")
+    }
+    playerID := args[1]
+
+    var err error
+    store, err = openPlayerStore(cfg.Database.Driver, cfg.Database.DSN)
+    if err != nil {
+        return err
+    }
+    defer store.Close()
+    if _, err := store.LoadPlayer(playerID); errors.Is(err, ErrPlayerNotFound) {
+        fmt.Printf("No saved player %s, creating the account anyway\n", playerID)
+    } else if err != nil {
+        return err
+    }
+
+    password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+    if err != nil && password == "" {
+        return fmt.Errorf("failed to read password from stdin: %v", err)
+    }
+    if err := createAccount(playerID, strings.TrimRight(password, "\r\n")); err != nil {
+        return err
+    }
+    fmt.Printf("Created account %s\n", playerID)
+    return nil
+}
+
+// **Read Credentials**
+// Handles CORS, method and rate limit checks and decodes the request body.
+func readCredentials(w http.ResponseWriter, r *http.Request) (Credentials, bool) {
+    var creds Credentials
+    origin := r.Header.Get("Origin")
+    w.Header().Add("Vary", "Origin")
+    if origin != "" && checkOrigin(origin) {
+        // Only ever name an allowed origin, or "*" when any is allowed
+        if allowsAnyOrigin() {
+            w.Header().Set("Access-Control-Allow-Origin", "*")
+        } else {
+            w.Header().Set("Access-Control-Allow-Origin", origin)
+        }
+        w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+        w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+    }
+    if r.Method == http.MethodOptions {
+        w.WriteHeader(http.StatusNoContent)
+        return creds, false
+    }
+    if r.Method != http.MethodPost {
+        writeJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+        return creds, false
+    }
+    if !allowAuthAttempt(r) {
+        WarningLogger.Printf("Too many login attempts from %s", r.RemoteAddr)
+        writeJSONResponse(w, http.StatusTooManyRequests, map[string]string{"error": "too many attempts, try again later"})
+        return creds, false
+    }
+    if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&creds); err != nil {
+        writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+        return creds, false
+    }
+    return creds, true
+}
+
+// **Write JSON Response**
+// Sends a JSON body with the given status code.
+func writeJSONResponse(w http.ResponseWriter, status int, body interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    if err := json.NewEncoder(w).Encode(body); err != nil {
+        ErrorLogger.Printf("Error writing HTTP response: %v", err)
+    }
+}
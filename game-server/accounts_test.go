@@ -0,0 +1,101 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+// **Use Auth Limit**
+// Sets the login budget and starts every IP afresh for the rest of the test.
+func useAuthLimit(t *testing.T, limit RateLimit) {
+    previous := config.RateLimit.Auth
+    config.RateLimit.Auth = limit
+    authLimitersMu.Lock()
+    authLimiters = make(map[string]*rateLimiter)
+    authLimitersMu.Unlock()
+    t.Cleanup(func() { config.RateLimit.Auth = previous })
+}
+
+func TestAllowAuthAttempt(t *testing.T) {
+    useAuthLimit(t, RateLimit{Rate: 0.001, Burst: 3})
+    attempt := func(addr string) bool {
+        r := httptest.NewRequest("POST", "/login", nil)
+        r.RemoteAddr = addr
+        return allowAuthAttempt(r)
+    }
+
+    for i := 0; i < 3; i++ {
+        if !attempt("10.0.0.1:1000") {
+            t.Fatalf("attempt %d refused within the burst", i+1)
+        }
+    }
+    if attempt("10.0.0.1:2000") {
+        t.Error("attempt past the burst allowed from another port of the same IP")
+    }
+    if !attempt("10.0.0.2:1000") {
+        t.Error("another IP was refused")
+    }
+}
+
+func TestRegisterAccountValidation(t *testing.T) {
+    tests := []struct {
+        name     string
+        playerID string
+        password string
+        wantErr  bool
+    }{
+        {name: "valid", playerID: "angler_1", password: "hunter22"},
+        {name: "longest password", playerID: "angler_1", password: strings.Repeat("p", maxPasswordLength)},
+        {name: "password too short", playerID: "angler_1", password: "hunter2", wantErr: true},
+        {name: "password too long for bcrypt", playerID: "angler_1", password: strings.Repeat("p", maxPasswordLength+1), wantErr: true},
+        {name: "bad player id", playerID: "angler 1", password: "hunter22", wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            useStore(t, newMemoryStore())
+            err := registerAccount(tt.playerID, tt.password)
+            if tt.wantErr {
+                if !errors.Is(err, ErrInvalidAccount) {
+                    t.Fatalf("registerAccount error = %v, want ErrInvalidAccount", err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("registerAccount error = %v", err)
+            }
+        })
+    }
+}
+
+// Players saved before accounts existed can only be registered while claims are on.
+func TestRegisterSavedPlayer(t *testing.T) {
+    for _, claims := range []bool{false, true} {
+        t.Run(fmt.Sprintf("claims %v", claims), func(t *testing.T) {
+            useStore(t, newMemoryStore())
+            player := savedTestPlayer(t, 100)
+            previous := config.Auth.ClaimSavedPlayers
+            config.Auth.ClaimSavedPlayers = claims
+            defer func() { config.Auth.ClaimSavedPlayers = previous }()
+
+            err := registerAccount(player.ID, "hunter22")
+            if !claims {
+                if !errors.Is(err, ErrPlayerClaimed) {
+                    t.Fatalf("registerAccount error = %v, want ErrPlayerClaimed", err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("registerAccount error = %v", err)
+            }
+            if err := registerAccount(player.ID, "hunter23"); !errors.Is(err, ErrAccountExists) {
+                t.Errorf("second registration error = %v, want ErrAccountExists", err)
+            }
+            if _, _, err := login(player.ID, "hunter22"); err != nil {
+                t.Errorf("login after claiming error = %v", err)
+            }
+        })
+    }
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// **Catalog Item Structure**
+// The server's authoritative definition of an item.
+// Prices, images and types always come from here, never from the client.
+type CatalogItem struct {
+    ID        string `json:"id"`        // Stable identifier (e.g. "redfish", "rocket-rod")
+    Type      string `json:"type"`      // Item category: "Fish" or "Pole"
+    Name      string `json:"name"`      // Display name
+    Value     int    `json:"value"`     // Price per unit
+    Img       string `json:"img"`       // Image path used by the client
+    Stackable bool   `json:"stackable"` // Plain units share one inventory slot
+}
+
+// **Item Catalog**
+// Every item known to the server keyed by ID, built from `fishList` and `poleList`.
+var itemCatalog = buildItemCatalog()
+
+// **Catalog Name Index**
+// Maps lower-cased item names to catalog IDs for references that predate item IDs.
+var catalogNameIndex = buildCatalogNameIndex(itemCatalog)
+
+// **Build Item Catalog**
+// Creates the catalog from the predefined fish and pole lists.
+func buildItemCatalog() map[string]CatalogItem {
+    catalog := make(map[string]CatalogItem)
+    for _, fish := range fishList {
+        entry := CatalogItem{
+            ID:        itemIDFromName(fish.Name),
+            Type:      fish.Type,
+            Name:      fish.Name,
+            Value:     fish.Value,
+            Img:       fish.Img,
+            Stackable: true,
+        }
+        catalog[entry.ID] = entry
+    }
+    for _, pole := range poleList {
+        entry := CatalogItem{
+            ID:    pole.ID,
+            Type:  pole.Type,
+            Name:  pole.Name,
+            Value: pole.Value,
+            Img:   pole.Img,
+        }
+        catalog[entry.ID] = entry
+    }
+    return catalog
+}
+
+// **Build Catalog Name Index**
+// Indexes catalog entries by their lower-cased display name.
+func buildCatalogNameIndex(catalog map[string]CatalogItem) map[string]string {
+    index := make(map[string]string, len(catalog))
+    for id, entry := range catalog {
+        index[strings.ToLower(entry.Name)] = id
+    }
+    return index
+}
+
+// **Item ID From Name**
+// Derives the ID used for fish, e.g. "Redfish" becomes "redfish".
+func itemIDFromName(name string) string {
+    return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "-"))
+}
+
+// **Lookup Catalog Item**
+// Resolves an item reference by ID, falling back to its name for older clients and saved inventories.
+func lookupCatalogItem(id, name string) (CatalogItem, error) {
+    if id != "" {
+        if entry, ok := itemCatalog[id]; ok {
+            return entry, nil
+        }
+        return CatalogItem{}, fmt.Errorf("unknown item id %q", id)
+    }
+    if name != "" {
+        if id, ok := catalogNameIndex[strings.ToLower(name)]; ok {
+            return itemCatalog[id], nil
+        }
+        return CatalogItem{}, fmt.Errorf("unknown item %q", name)
+    }
+    return CatalogItem{}, fmt.Errorf("item id or name is required")
+}
+
+// **To Item**
+// Builds an inventory item from the catalog entry. It gets a UID when it's added to an inventory.
+func (c CatalogItem) toItem(quantity int) Item {
+    item := Item{
+        ID:       c.ID,
+        Type:     c.Type,
+        Name:     c.Name,
+        Quantity: quantity,
+        Value:    c.Value,
+        Img:      c.Img,
+    }
+    if stats, ok := rodStats[c.ID]; ok {
+        item.Rod = newRodState(stats)
+    }
+    return item
+}
+
+// **Sell Price**
+// Returns what the server pays for the items, pricing caught fish by their
+// size and quality.
+func (c CatalogItem) sellPrice(items []Item) int {
+    price := 0
+    for _, item := range items {
+        item.Value = c.Value
+        price += item.worth()
+    }
+    return price
+}
+
+// **Normalize Inventory**
+// Replaces stored value/img/type with catalog data and drops items the catalog
+// no longer knows. Instance data (UID, catch, rod state, acquisition time) is
+// kept, with rod durability capped to what the catalog rod has now.
+func normalizeInventory(playerID string, inventory []Item) []Item {
+    normalized := make([]Item, 0, len(inventory))
+    for _, item := range inventory {
+        entry, err := lookupCatalogItem(item.ID, item.Name)
+        if err != nil {
+            WarningLogger.Printf("Dropping inventory item %q for player %s: %v", item.Name, playerID, err)
+            continue
+        }
+        normalizedItem := entry.toItem(item.Quantity)
+        normalizedItem.UID = item.UID
+        normalizedItem.Catch = item.Catch
+        if item.Rod != nil && normalizedItem.Rod != nil {
+            normalizedItem.Rod = item.Rod.withMaxDurability(normalizedItem.Rod.MaxDurability)
+        }
+        normalizedItem.AcquiredAt = item.AcquiredAt
+        normalized = append(normalized, normalizedItem)
+    }
+    return normalized
+}
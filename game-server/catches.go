@@ -0,0 +1,117 @@
+package main
+
+import (
+    "math"
+    "math/rand"
+    "time"
+)
+
+// **Catch Qualities**
+const (
+    QualityNormal = "normal"
+    QualitySilver = "silver"
+    QualityGold   = "gold"
+)
+
+// **Quality Multipliers**
+// How much more a catch of each quality sells for.
+var qualityMultipliers = map[string]float64{
+    QualityNormal: 1,
+    QualitySilver: 1.5,
+    QualityGold:   3,
+}
+
+// **Catch Structure**
+// One fish as it was landed. A fish with a catch is a unique item, fish
+// caught before catches were rolled have none and stack.
+type Catch struct {
+    Length   float64 `json:"length"`   // Centimetres
+    Weight   float64 `json:"weight"`   // Kilograms
+    Quality  string  `json:"quality"`  // "normal", "silver" or "gold"
+    CaughtAt int64   `json:"caughtAt"` // Unix milliseconds
+}
+
+// **Catch Roll Structure**
+// How the catches of a species are distributed.
+type CatchRoll struct {
+    Length       float64 // Average length in centimetres
+    LengthSpread float64 // Standard deviation of the length, as a fraction of the average
+    Weight       float64 // Weight in kilograms of a fish of average length
+    SilverChance float64 // Chance (0-1) of a silver catch
+    GoldChance   float64 // Chance (0-1) of a gold catch
+}
+
+// **Catch Rolls By ID**
+// Distributions for every fish in `fishList`, keyed by catalog ID.
+var catchRolls = map[string]CatchRoll{
+    "redfish":    {Length: 45, LengthSpread: 0.15, Weight: 1.5, SilverChance: 0.1, GoldChance: 0.02},
+    "commonfish": {Length: 25, LengthSpread: 0.15, Weight: 0.3, SilverChance: 0.08, GoldChance: 0.01},
+    "guppie":     {Length: 4, LengthSpread: 0.2, Weight: 0.005, SilverChance: 0.08, GoldChance: 0.01},
+    "clownfish":  {Length: 10, LengthSpread: 0.15, Weight: 0.05, SilverChance: 0.1, GoldChance: 0.02},
+    "rarefish":   {Length: 60, LengthSpread: 0.2, Weight: 3, SilverChance: 0.12, GoldChance: 0.03},
+    "tuna":       {Length: 150, LengthSpread: 0.2, Weight: 60, SilverChance: 0.1, GoldChance: 0.02},
+    "swordfish":  {Length: 250, LengthSpread: 0.2, Weight: 150, SilverChance: 0.1, GoldChance: 0.02},
+    "golden-koi": {Length: 70, LengthSpread: 0.15, Weight: 8, SilverChance: 0.15, GoldChance: 0.05},
+    "leviathan":  {Length: 900, LengthSpread: 0.25, Weight: 2000, SilverChance: 0.12, GoldChance: 0.03},
+}
+
+// **Roll Catch**
+// Rolls the length, weight and quality of a landed fish. Length is normally
+// distributed around the species average, weight grows with its cube and
+// varies a little on top, and rod luck improves the odds of silver and gold.
+func rollCatch(fish Fish, rod RodStats, now time.Time) Catch {
+    roll, ok := catchRolls[itemIDFromName(fish.Name)]
+    if !ok {
+        return Catch{Quality: QualityNormal, CaughtAt: now.UnixMilli()}
+    }
+
+    scale := 1 + roll.LengthSpread*rand.NormFloat64()
+    scale = math.Max(0.5, math.Min(2, scale))
+    weight := roll.Weight * scale * scale * scale * (1 + 0.1*rand.NormFloat64())
+    weight = math.Max(roll.Weight*0.1, weight)
+
+    quality := QualityNormal
+    luck := 1 + rod.Luck
+    switch r := rand.Float64(); {
+    case r < roll.GoldChance*luck:
+        quality = QualityGold
+    case r < (roll.GoldChance+roll.SilverChance)*luck:
+        quality = QualitySilver
+    }
+
+    return Catch{
+        Length:   math.Round(roll.Length*scale*10) / 10,
+        Weight:   math.Round(weight*1000) / 1000,
+        Quality:  quality,
+        CaughtAt: now.UnixMilli(),
+    }
+}
+
+// **Catch Price**
+// What the catch sells for given its species' base `value`: heavier than
+// average pays more, lighter pays less, and quality multiplies the lot.
+func (c Catch) price(id string, value int) int {
+    size := 1.0
+    if roll, ok := catchRolls[id]; ok && roll.Weight > 0 {
+        size = math.Max(0.5, math.Min(3, c.Weight/roll.Weight))
+    }
+    multiplier, ok := qualityMultipliers[c.Quality]
+    if !ok {
+        multiplier = 1
+    }
+    return int(math.Max(1, math.Round(float64(value)*size*multiplier)))
+}
+
+// **Record Catch (Locked)**
+// Keeps the catch as the player's personal record for the species if it's
+// their heaviest yet. Reports whether it was. Caller must hold `mu`.
+func recordCatchLocked(player *Player, fishID string, catch Catch) bool {
+    if best, ok := player.Records[fishID]; ok && best.Weight >= catch.Weight {
+        return false
+    }
+    if player.Records == nil {
+        player.Records = make(map[string]Catch)
+    }
+    player.Records[fishID] = catch
+    return true
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+    "errors"
+    "sync"
+    "time"
+
+    "github.com/gorilla/websocket"
+)
+
+// **Client Errors**
+// Returned by `Client.Send` when a message can't be queued.
+var (
+    ErrClientClosed = errors.New("client connection closed")
+    ErrSlowConsumer = errors.New("client send queue full")
+)
+
+// **Client Structure**
+// Owns a player's WebSocket connection. All writes go through a buffered queue
+// drained by a single writer goroutine, as gorilla/websocket allows only one writer.
+type Client struct {
+    conn        *websocket.Conn
+    playerID    string
+    send        chan []byte   // Encoded messages waiting to be written
+    done        chan struct{} // Closed when the client shuts down
+    closeOnce   sync.Once
+    closeReason string // Sent in the close frame after a kick, set before the sentinel is queued
+    version     int    // Protocol version negotiated in `join`
+    codec       Codec  // Wire encoding negotiated in `join`
+    replicated  map[string]PlayerState // Public state of each player as last sent to this client. Guarded by `mu`
+    resync      bool                   // Send every player in full in the next snapshot. Guarded by `mu`
+}
+
+// **Client Lock**
+// Guards `Player.Client`. It's written with both `mu` and `clientMu` held, so
+// holding either one is enough to read it.
+var clientMu sync.RWMutex
+
+// **New Client**
+// Wraps a connection and starts its writer goroutine.
+func newClient(conn *websocket.Conn, playerID string, version int, codec Codec) *Client {
+    c := &Client{
+        conn:       conn,
+        playerID:   playerID,
+        version:    version,
+        codec:      codec,
+        replicated: make(map[string]PlayerState),
+        send:       make(chan []byte, config.Server.SendQueueSize),
+        done:       make(chan struct{}),
+    }
+    go c.writePump()
+    return c
+}
+
+// **New Detached Client**
+// A client without a connection that holds on to messages for a disconnected
+// player until they resume. It closes itself once the queue fills up. It holds
+// half a send queue, so replaying it on resume leaves room for new messages.
+// Messages are encoded for the connection that dropped, and replicated state
+// carries on from it.
+func newDetachedClient(previous *Client) *Client {
+    return &Client{
+        playerID:   previous.playerID,
+        version:    previous.version,
+        codec:      previous.codec,
+        replicated: previous.replicated,
+        send:       make(chan []byte, config.Server.SendQueueSize/2),
+        done:       make(chan struct{}),
+    }
+}
+
+// **Send**
+// Encodes the message and queues it without blocking. A client whose queue is
+// full is too slow to keep up and gets disconnected.
+func (c *Client) Send(msg Message) error {
+    data, err := c.codec.Marshal(msg)
+    if err != nil {
+        return err
+    }
+    return c.sendRaw(data)
+}
+
+// **Send Raw**
+// Queues a message already encoded with the client's codec, letting broadcasts
+// encode only once per codec.
+func (c *Client) sendRaw(data []byte) error {
+    select {
+    case <-c.done:
+        return ErrClientClosed
+    default:
+    }
+
+    select {
+    case c.send <- data:
+        return nil
+    default:
+        if c.conn == nil {
+            DebugLogger.Printf("Too many missed messages for player %s, session can't be resumed", c.playerID)
+        } else {
+            WarningLogger.Printf("Send queue full for player %s, disconnecting slow client", c.playerID)
+        }
+        c.Close()
+        return ErrSlowConsumer
+    }
+}
+
+// **Kick**
+// Queues a final message, then closes the connection once everything queued
+// before it has been written.
+func (c *Client) Kick(msg Message, reason string) {
+    c.closeReason = reason
+    if err := c.Send(msg); err != nil {
+        c.Close()
+        return
+    }
+    // A nil entry tells the writer to close
+    if err := c.sendRaw(nil); err != nil {
+        c.Close()
+    }
+}
+
+// **Take Queued**
+// Empties a detached client's queue, returning false if messages were dropped.
+func (c *Client) takeQueued() ([][]byte, bool) {
+    select {
+    case <-c.done:
+        return nil, false
+    default:
+    }
+    var queued [][]byte
+    for {
+        select {
+        case data := <-c.send:
+            queued = append(queued, data)
+        default:
+            return queued, true
+        }
+    }
+}
+
+// **Close**
+// Stops the writer and closes the connection, which also ends the read loop.
+func (c *Client) Close() {
+    c.closeOnce.Do(func() {
+        close(c.done)
+        if c.conn != nil {
+            c.conn.Close()
+        }
+    })
+}
+
+// **Write Pump**
+// Writes queued messages to the connection until the client is closed, and
+// pings the client every `PingInterval` so dead connections are noticed.
+func (c *Client) writePump() {
+    ping := time.NewTicker(config.Server.PingInterval.Duration)
+    defer ping.Stop()
+    defer c.Close()
+    for {
+        select {
+        case <-ping.C:
+            deadline := time.Now().Add(config.Server.WriteTimeout.Duration)
+            if err := c.conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+                ErrorLogger.Printf("Error pinging player %s: %v", c.playerID, err)
+                return
+            }
+        case data := <-c.send:
+            c.conn.SetWriteDeadline(time.Now().Add(config.Server.WriteTimeout.Duration))
+            if data == nil {
+                closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, c.closeReason)
+                c.conn.WriteMessage(websocket.CloseMessage, closeMsg)
+                return
+            }
+            if err := c.conn.WriteMessage(c.codec.FrameType(), data); err != nil {
+                ErrorLogger.Printf("Error writing to player %s: %v", c.playerID, err)
+                return
+            }
+        case <-c.done:
+            return
+        }
+    }
+}
+
+// **Send**
+// Queues a message for the player.
+func (p *Player) Send(msg Message) error {
+    clientMu.RLock()
+    client := p.Client
+    clientMu.RUnlock()
+    if client == nil {
+        return ErrClientClosed
+    }
+    return client.Send(msg)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+
+    "github.com/gorilla/websocket"
+    "github.com/vmihailenco/msgpack/v5"
+    "github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// **Codec Interface**
+// A wire encoding for messages. JSON travels in text frames and MessagePack in
+// binary frames, so either side can tell them apart without negotiating first.
+type Codec interface {
+    // Name is how clients ask for the codec in `join`.
+    Name() string
+    // FrameType is the WebSocket message type the codec's messages are sent in.
+    FrameType() int
+    // Marshal encodes an outbound message.
+    Marshal(v interface{}) ([]byte, error)
+    // DecodeEnvelope decodes a client message, leaving its payload encoded.
+    DecodeEnvelope(raw []byte) (Envelope, error)
+    // DecodeStrict decodes a payload, rejecting unknown fields and trailing data.
+    DecodeStrict(data []byte, v interface{}) error
+}
+
+// **Codecs**
+// The codecs the server speaks, by name.
+var (
+    jsonCodec    Codec = jsonWireCodec{}
+    msgpackCodec Codec = msgpackWireCodec{}
+
+    codecs = map[string]Codec{
+        jsonCodec.Name():    jsonCodec,
+        msgpackCodec.Name(): msgpackCodec,
+    }
+)
+
+// **Codec For Frame**
+// The codec a received WebSocket message is in.
+func codecForFrame(frameType int) Codec {
+    if frameType == websocket.BinaryMessage {
+        return msgpackCodec
+    }
+    return jsonCodec
+}
+
+// **Negotiate Codec**
+// Picks the first codec in the client's preference list that the server
+// speaks, falling back to JSON which every client understands.
+func negotiateCodec(names []string) Codec {
+    for _, name := range names {
+        if codec, ok := codecs[name]; ok {
+            return codec
+        }
+    }
+    return jsonCodec
+}
+
+// **JSON Codec**
+type jsonWireCodec struct{}
+
+// jsonEnvelope is the JSON form of `Envelope`.
+type jsonEnvelope struct {
+    Type      string          `json:"type"`
+    RequestID string          `json:"requestId,omitempty"`
+    Data      json.RawMessage `json:"data,omitempty"`
+}
+
+func (jsonWireCodec) Name() string   { return "json" }
+func (jsonWireCodec) FrameType() int { return websocket.TextMessage }
+
+func (jsonWireCodec) Marshal(v interface{}) ([]byte, error) {
+    return json.Marshal(v)
+}
+
+func (c jsonWireCodec) DecodeEnvelope(raw []byte) (Envelope, error) {
+    var env jsonEnvelope
+    if err := c.DecodeStrict(raw, &env); err != nil {
+        return Envelope{}, err
+    }
+    data := []byte(env.Data)
+    if string(data) == "null" {
+        data = nil
+    }
+    return Envelope{Type: env.Type, RequestID: env.RequestID, Data: data}, nil
+}
+
+func (jsonWireCodec) DecodeStrict(data []byte, v interface{}) error {
+    dec := json.NewDecoder(bytes.NewReader(data))
+    dec.DisallowUnknownFields()
+    if err := dec.Decode(v); err != nil {
+        return err
+    }
+    if dec.More() {
+        return errors.New("unexpected data after message")
+    }
+    return nil
+}
+
+// **MessagePack Codec**
+// Uses the `json` struct tags so both codecs put the same field names on the wire.
+type msgpackWireCodec struct{}
+
+// msgpackEnvelope is the MessagePack form of `Envelope`.
+type msgpackEnvelope struct {
+    Type      string             `json:"type"`
+    RequestID string             `json:"requestId,omitempty"`
+    Data      msgpack.RawMessage `json:"data,omitempty"`
+}
+
+func (msgpackWireCodec) Name() string   { return "msgpack" }
+func (msgpackWireCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (msgpackWireCodec) Marshal(v interface{}) ([]byte, error) {
+    var buf bytes.Buffer
+    enc := msgpack.NewEncoder(&buf)
+    enc.SetCustomStructTag("json")
+    enc.UseCompactInts(true)
+    if err := enc.Encode(v); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+func (c msgpackWireCodec) DecodeEnvelope(raw []byte) (Envelope, error) {
+    var env msgpackEnvelope
+    if err := c.DecodeStrict(raw, &env); err != nil {
+        return Envelope{}, err
+    }
+    data := []byte(env.Data)
+    if len(data) == 1 && data[0] == msgpcode.Nil {
+        data = nil
+    }
+    return Envelope{Type: env.Type, RequestID: env.RequestID, Data: data}, nil
+}
+
+func (msgpackWireCodec) DecodeStrict(data []byte, v interface{}) error {
+    r := bytes.NewReader(data)
+    dec := msgpack.NewDecoder(r)
+    dec.SetCustomStructTag("json")
+    dec.DisallowUnknownFields(true)
+    if err := dec.Decode(v); err != nil {
+        return err
+    }
+    if r.Len() > 0 {
+        return errors.New("unexpected data after message")
+    }
+    return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+    "bytes"
+    "encoding/hex"
+    "encoding/json"
+    "flag"
+    "os"
+    "strings"
+    "testing"
+)
+
+var updateFixtures = flag.Bool("update", false, "rewrite the files in testdata")
+
+// **Msgpack Fixture**
+// A value as the server encodes it, for the client's MessagePack tests in
+// game/js/network/msgpack_test.js. `Exact` fixtures have no whole-number
+// floats, which the client writes as integers, so its encoding must match
+// byte for byte.
+type msgpackFixture struct {
+    Name    string          `json:"name"`
+    Value   json.RawMessage `json:"value"`
+    Msgpack string          `json:"msgpack"` // Hex
+    Exact   bool            `json:"exact"`
+}
+
+const msgpackFixturesPath = "testdata/msgpack_fixtures.json"
+
+// The fixtures must be what the server's encoder writes today. Run with
+// `-update` after changing them or the codec.
+func TestMsgpackFixtures(t *testing.T) {
+    x, tick := 7, uint64(5000000000)
+    values := []struct {
+        name  string
+        value interface{}
+        exact bool
+    }{
+        {"nil", nil, true},
+        {"true", true, true},
+        {"false", false, true},
+        {"positive fixint", 127, true},
+        {"uint8", 128, true},
+        {"uint8 max", 255, true},
+        {"uint16", 256, true},
+        {"uint16 max", 65535, true},
+        {"uint32", 65536, true},
+        {"uint32 max", 4294967295, true},
+        {"uint64", 4294967296, true},
+        {"largest safe integer", 9007199254740991, true},
+        {"negative fixint", -32, true},
+        {"int8", -33, true},
+        {"int8 min", -128, true},
+        {"int16", -129, true},
+        {"int16 min", -32768, true},
+        {"int32", -32769, true},
+        {"int32 min", -2147483648, true},
+        {"int64", -2147483649, true},
+        {"smallest safe integer", -9007199254740991, true},
+        {"float", 1.5, true},
+        {"negative float", -0.25, true},
+        {"empty string", "", true},
+        {"fixstr max", strings.Repeat("f", 31), true},
+        {"str8", strings.Repeat("f", 32), true},
+        {"str16", strings.Repeat("f", 256), true},
+        {"unicode string", "Grüße 🐟", true},
+        {"array16", make([]int, 16), true},
+        {"snapshot", Message{Type: "snapshot", Data: SnapshotData{
+            Tick:    tick,
+            Entered: []PlayerState{{ID: "angler", X: 300, Y: 70000, Direction: "left", EquippedRod: "rod-solid"}},
+            Players: []PlayerDelta{{ID: "other", X: &x}},
+            Left:    []string{"gone"},
+        }}, true},
+        {"reel status", Message{Type: "fishingEvent", RequestID: "r-1", Data: FishingEventData{
+            Event:    "reel",
+            PlayerID: "angler",
+            Line:     &LineStatus{Tension: 0.5, Progress: 0.25, Reeling: true, RemainingMs: 4200},
+        }}, true},
+        {"slack line", Message{Type: "fishingEvent", Data: FishingEventData{
+            Event:    "reel",
+            PlayerID: "angler",
+            Line:     &LineStatus{Tension: 0, Progress: 1, RemainingMs: -5},
+        }}, false},
+        {"catch", Message{Type: "fishingEvent", Data: FishingEventData{
+            Event:    "catch",
+            PlayerID: "angler",
+            Catch:    &Catch{Length: 41.5, Weight: 1.125, Quality: "gold", CaughtAt: 1760781600123},
+            Price:    340,
+            Record:   true,
+        }}, true},
+    }
+
+    var fixtures []msgpackFixture
+    for _, v := range values {
+        packed, err := msgpackCodec.Marshal(v.value)
+        if err != nil {
+            t.Fatalf("%s: msgpack: %v", v.name, err)
+        }
+        value, err := json.Marshal(v.value)
+        if err != nil {
+            t.Fatalf("%s: json: %v", v.name, err)
+        }
+        fixtures = append(fixtures, msgpackFixture{Name: v.name, Value: value, Msgpack: hex.EncodeToString(packed), Exact: v.exact})
+    }
+    encoded, err := json.MarshalIndent(fixtures, "", "  ")
+    if err != nil {
+        t.Fatalf("encode fixtures: %v", err)
+    }
+    encoded = append(encoded, '\n')
+
+    if *updateFixtures {
+        if err := os.WriteFile(msgpackFixturesPath, encoded, 0644); err != nil {
+            t.Fatalf("write fixtures: %v", err)
+        }
+    }
+    saved, err := os.ReadFile(msgpackFixturesPath)
+    if err != nil {
+        t.Fatalf("read fixtures: %v", err)
+    }
+    if !bytes.Equal(saved, encoded) {
+        t.Errorf("%s is out of date, run go test -run TestMsgpackFixtures -update", msgpackFixturesPath)
+    }
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// **Duration**
+// A time.Duration that reads and writes as a string such as "3s" or "1m" in JSON.
+type Duration struct {
+    time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+    return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+    var s string
+    if err := json.Unmarshal(b, &s); err != nil {
+        return fmt.Errorf("duration must be a string like \"3s\": %v", err)
+    }
+    parsed, err := time.ParseDuration(s)
+    if err != nil {
+        return err
+    }
+    d.Duration = parsed
+    return nil
+}
+
+// **Config Structure**
+// All server settings. Values are layered: defaults, then the config file,
+// then FISHPALS_* environment variables, then command line flags.
+type Config struct {
+    Server    ServerConfig    `json:"server"`
+    Database  DatabaseConfig  `json:"database"`
+    World     WorldConfig     `json:"world"`
+    Fishing   FishingConfig   `json:"fishing"`
+    Inventory InventoryConfig `json:"inventory"`
+    Auth      AuthConfig      `json:"auth"`
+    RateLimit RateLimitConfig `json:"rateLimit"`
+}
+
+// **Server Config**
+// Networking and housekeeping settings.
+type ServerConfig struct {
+    Addr           string   `json:"addr"`           // Listen address, e.g. ":8081"
+    AllowedOrigins []string `json:"allowedOrigins"` // Origins allowed to connect and log in, "*" allows any site
+    SaveInterval   Duration `json:"saveInterval"`   // How often connected players are saved
+    SendQueueSize  int      `json:"sendQueueSize"`  // Messages buffered per client before it counts as too slow
+    WriteTimeout   Duration `json:"writeTimeout"`   // Longest a single WebSocket write may take
+    TickRate       int      `json:"tickRate"`       // Game loop ticks per second
+    ResumeGrace    Duration `json:"resumeGrace"`    // How long a disconnected player's session is kept for resuming, 0 disables
+    PingInterval   Duration `json:"pingInterval"`   // How often clients are pinged to check the connection is alive
+    ReadTimeout    Duration `json:"readTimeout"`    // Connections silent for this long, pongs included, are dropped
+    AFKTimeout     Duration `json:"afkTimeout"`     // Players who send nothing for this long are flagged AFK, 0 disables
+}
+
+// **Database Config**
+// Which player store to use and how to reach it.
+type DatabaseConfig struct {
+    Driver string `json:"driver"` // "mysql", "sqlite" or "memory"
+    DSN    string `json:"dsn"`    // Driver specific data source name
+}
+
+// **World Config**
+// Map generation and replication settings.
+type WorldConfig struct {
+    Width      int `json:"width"`      // Map width in tiles
+    Height     int `json:"height"`     // Map height in tiles
+    ViewRadius int `json:"viewRadius"` // Players farther than this many tiles away aren't sent to each other
+}
+
+// **Fishing Config**
+// Base fishing tuning used when the player has no rod equipped.
+type FishingConfig struct {
+    BiteChance  float64  `json:"biteChance"`  // Chance (0-1) that a fish bites
+    MinWait     Duration `json:"minWait"`     // Shortest wait before a bite
+    MaxWait     Duration `json:"maxWait"`     // Longest wait before a bite
+    CatchWindow Duration `json:"catchWindow"` // Time to react to a bite
+    CastTime    Duration `json:"castTime"`    // How long the line is in the air before the wait starts, for any rod
+    ReelGrace   Duration `json:"reelGrace"`   // Time on top of twice a fish's fight time before it gets away
+    MinReaction Duration `json:"minReaction"` // Catch attempts quicker than this after a bite are rejected as automated
+}
+
+// **Inventory Config**
+// Player inventory limits.
+type InventoryConfig struct {
+    Capacity int `json:"capacity"` // Slots per player, each stack or unique item takes one
+}
+
+// **Auth Config**
+// Account and session token settings.
+type AuthConfig struct {
+    Required    bool     `json:"required"`    // Reject `join` messages without a valid session token
+    TokenSecret string   `json:"tokenSecret"` // HMAC key for session tokens, random per run if empty
+    TokenTTL    Duration `json:"tokenTTL"`    // How long a session token stays valid
+    ClaimSavedPlayers bool `json:"claimSavedPlayers"` // Let the first registration for a player saved before accounts claim them
+}
+
+// **Example Token Secret**
+// The placeholder older copies of config.example.json shipped with. It's
+// public, so tokens signed with it could be forged by anyone.
+const exampleTokenSecret = "replace-with-a-long-random-string"
+
+// **Rate Limit Config**
+// How many messages of each type a player may send. Entries in a config file
+// are merged over the defaults.
+type RateLimitConfig struct {
+    Messages     map[string]RateLimit `json:"messages"`     // Budget per message type, "default" covers the rest
+    MaxStrikes   int                  `json:"maxStrikes"`   // Messages rejected within strikeWindow before disconnecting
+    StrikeWindow Duration             `json:"strikeWindow"` // How long a rejected message counts against the player
+    Auth         RateLimit            `json:"auth"`         // Login and registration attempts per client IP
+}
+
+// **Rate Limit**
+// A token bucket budget.
+type RateLimit struct {
+    Rate  float64 `json:"rate"`  // Messages per second on average
+    Burst int     `json:"burst"` // Messages allowed at once after a quiet spell
+}
+
+// **Default Game Origin**
+// Where the game client is served from in development.
+const defaultGameOrigin = "http://localhost:8000"
+
+// **Server Configuration**
+// The effective configuration, loaded in `main`.
+var config = defaultConfig()
+
+// **Default Config**
+// Settings used when nothing else is provided. Good enough to run on a laptop.
+func defaultConfig() Config {
+    return Config{
+        Server: ServerConfig{
+            Addr:           ":8081",
+            AllowedOrigins: []string{defaultGameOrigin},
+            SaveInterval:   Duration{1 * time.Minute},
+            SendQueueSize:  256,
+            WriteTimeout:   Duration{10 * time.Second},
+            TickRate:       20,
+            ResumeGrace:    Duration{30 * time.Second},
+            PingInterval:   Duration{20 * time.Second},
+            ReadTimeout:    Duration{60 * time.Second},
+            AFKTimeout:     Duration{5 * time.Minute},
+        },
+        Database: DatabaseConfig{
+            Driver: "sqlite",
+            DSN:    "fishpals.db",
+        },
+        World: WorldConfig{
+            Width:      50,
+            Height:     50,
+            ViewRadius: 16,
+        },
+        Fishing: FishingConfig{
+            BiteChance:  0.8,
+            MinWait:     Duration{1 * time.Second},
+            MaxWait:     Duration{5 * time.Second},
+            CatchWindow: Duration{3 * time.Second},
+            CastTime:    Duration{500 * time.Millisecond},
+            ReelGrace:   Duration{5 * time.Second},
+            MinReaction: Duration{120 * time.Millisecond},
+        },
+        Inventory: InventoryConfig{
+            Capacity: 30,
+        },
+        Auth: AuthConfig{
+            Required: true,
+            TokenTTL: Duration{24 * time.Hour},
+        },
+        RateLimit: RateLimitConfig{
+            Messages: map[string]RateLimit{
+                "default":       {Rate: 10, Burst: 20},
+                "move":          {Rate: 10, Burst: 15},
+                "fish":          {Rate: 1, Burst: 3},
+                "catchAttempt":  {Rate: 2, Burst: 4},
+                "reel":          {Rate: 10, Burst: 20},
+                "sellItem":      {Rate: 5, Burst: 10},
+                "buyItem":       {Rate: 5, Burst: 10},
+                "equipRod":      {Rate: 2, Burst: 5},
+                "shopCatalog":   {Rate: 1, Burst: 3},
+                "sortInventory": {Rate: 1, Burst: 3},
+                "repairRod":     {Rate: 2, Burst: 5},
+                "upgradeRod":    {Rate: 2, Burst: 5},
+            },
+            MaxStrikes:   50,
+            StrikeWindow: Duration{10 * time.Second},
+            Auth:         RateLimit{Rate: 0.2, Burst: 10},
+        },
+    }
+}
+
+// **Load Config**
+// Builds the effective configuration from the config file, environment and command line.
+// Arguments left after the flags (such as a subcommand) are returned as well.
+func loadConfig(args []string) (Config, []string, error) {
+    cfg := defaultConfig()
+
+    fs := flag.NewFlagSet("fishserver", flag.ContinueOnError)
+    configPath := fs.String("config", os.Getenv("FISHPALS_CONFIG"), "path to a JSON config file")
+    addr := fs.String("addr", "", "listen address (server.addr)")
+    driver := fs.String("db-driver", "", "player store driver: mysql, sqlite or memory (database.driver)")
+    dsn := fs.String("db-dsn", "", "player store data source name (database.dsn)")
+    if err := fs.Parse(args); err != nil {
+        return cfg, nil, err
+    }
+
+    if *configPath != "" {
+        data, err := os.ReadFile(*configPath)
+        if err != nil {
+            return cfg, nil, fmt.Errorf("failed to read config file: %v", err)
+        }
+        decoder := json.NewDecoder(bytes.NewReader(data))
+        decoder.DisallowUnknownFields()
+        if err := decoder.Decode(&cfg); err != nil {
+            return cfg, nil, fmt.Errorf("failed to parse config file %s: %v", *configPath, err)
+        }
+    }
+
+    if err := applyEnv(&cfg); err != nil {
+        return cfg, nil, err
+    }
+
+    // Flags win over everything, but only when actually given
+    fs.Visit(func(f *flag.Flag) {
+        switch f.Name {
+        case "addr":
+            cfg.Server.Addr = *addr
+        case "db-driver":
+            cfg.Database.Driver = *driver
+        case "db-dsn":
+            cfg.Database.DSN = *dsn
+        }
+    })
+
+    return cfg, fs.Args(), cfg.validate()
+}
+
+// **Apply Env**
+// Overrides settings from FISHPALS_* environment variables.
+func applyEnv(cfg *Config) error {
+    var err error
+    setString := func(name string, target *string) {
+        if v, ok := os.LookupEnv(name); ok {
+            *target = v
+        }
+    }
+    setInt := func(name string, target *int) {
+        if v, ok := os.LookupEnv(name); ok && err == nil {
+            if *target, err = strconv.Atoi(v); err != nil {
+                err = fmt.Errorf("%s: %v", name, err)
+            }
+        }
+    }
+    setFloat := func(name string, target *float64) {
+        if v, ok := os.LookupEnv(name); ok && err == nil {
+            if *target, err = strconv.ParseFloat(v, 64); err != nil {
+                err = fmt.Errorf("%s: %v", name, err)
+            }
+        }
+    }
+    setDuration := func(name string, target *Duration) {
+        if v, ok := os.LookupEnv(name); ok && err == nil {
+            if target.Duration, err = time.ParseDuration(v); err != nil {
+                err = fmt.Errorf("%s: %v", name, err)
+            }
+        }
+    }
+
+    setString("FISHPALS_ADDR", &cfg.Server.Addr)
+    if v, ok := os.LookupEnv("FISHPALS_ALLOWED_ORIGINS"); ok {
+        cfg.Server.AllowedOrigins = strings.Split(v, ",")
+    }
+    setDuration("FISHPALS_SAVE_INTERVAL", &cfg.Server.SaveInterval)
+    setInt("FISHPALS_SEND_QUEUE_SIZE", &cfg.Server.SendQueueSize)
+    setDuration("FISHPALS_WRITE_TIMEOUT", &cfg.Server.WriteTimeout)
+    setInt("FISHPALS_TICK_RATE", &cfg.Server.TickRate)
+    setDuration("FISHPALS_RESUME_GRACE", &cfg.Server.ResumeGrace)
+    setDuration("FISHPALS_PING_INTERVAL", &cfg.Server.PingInterval)
+    setDuration("FISHPALS_READ_TIMEOUT", &cfg.Server.ReadTimeout)
+    setDuration("FISHPALS_AFK_TIMEOUT", &cfg.Server.AFKTimeout)
+    setString("FISHPALS_DB_DRIVER", &cfg.Database.Driver)
+    setString("FISHPALS_DB_DSN", &cfg.Database.DSN)
+    setInt("FISHPALS_WORLD_WIDTH", &cfg.World.Width)
+    setInt("FISHPALS_WORLD_HEIGHT", &cfg.World.Height)
+    setInt("FISHPALS_VIEW_RADIUS", &cfg.World.ViewRadius)
+    setFloat("FISHPALS_BITE_CHANCE", &cfg.Fishing.BiteChance)
+    setDuration("FISHPALS_MIN_WAIT", &cfg.Fishing.MinWait)
+    setDuration("FISHPALS_MAX_WAIT", &cfg.Fishing.MaxWait)
+    setDuration("FISHPALS_CATCH_WINDOW", &cfg.Fishing.CatchWindow)
+    setDuration("FISHPALS_CAST_TIME", &cfg.Fishing.CastTime)
+    setDuration("FISHPALS_REEL_GRACE", &cfg.Fishing.ReelGrace)
+    setDuration("FISHPALS_MIN_REACTION", &cfg.Fishing.MinReaction)
+    setInt("FISHPALS_INVENTORY_CAPACITY", &cfg.Inventory.Capacity)
+    if v, ok := os.LookupEnv("FISHPALS_AUTH_REQUIRED"); ok && err == nil {
+        if cfg.Auth.Required, err = strconv.ParseBool(v); err != nil {
+            err = fmt.Errorf("FISHPALS_AUTH_REQUIRED: %v", err)
+        }
+    }
+    if v, ok := os.LookupEnv("FISHPALS_AUTH_CLAIM_SAVED_PLAYERS"); ok && err == nil {
+        if cfg.Auth.ClaimSavedPlayers, err = strconv.ParseBool(v); err != nil {
+            err = fmt.Errorf("FISHPALS_AUTH_CLAIM_SAVED_PLAYERS: %v", err)
+        }
+    }
+    setString("FISHPALS_TOKEN_SECRET", &cfg.Auth.TokenSecret)
+    setDuration("FISHPALS_TOKEN_TTL", &cfg.Auth.TokenTTL)
+    setInt("FISHPALS_RATE_MAX_STRIKES", &cfg.RateLimit.MaxStrikes)
+    setDuration("FISHPALS_RATE_STRIKE_WINDOW", &cfg.RateLimit.StrikeWindow)
+    return err
+}
+
+// **Validate**
+// Checks the configuration for values the server can't run with.
+func (c Config) validate() error {
+    var problems []string
+    if c.Server.Addr == "" {
+        problems = append(problems, "server.addr is required")
+    }
+    if len(c.Server.AllowedOrigins) == 0 {
+        problems = append(problems, "server.allowedOrigins needs at least one origin (use \"*\" to allow any)")
+    }
+    if c.Server.SaveInterval.Duration <= 0 {
+        problems = append(problems, "server.saveInterval must be positive")
+    }
+    if c.Server.SendQueueSize < 1 {
+        problems = append(problems, "server.sendQueueSize must be at least 1")
+    }
+    if c.Server.WriteTimeout.Duration <= 0 {
+        problems = append(problems, "server.writeTimeout must be positive")
+    }
+    if c.Server.TickRate < 1 || c.Server.TickRate > 1000 {
+        problems = append(problems, "server.tickRate must be between 1 and 1000")
+    }
+    if c.Server.ResumeGrace.Duration < 0 {
+        problems = append(problems, "server.resumeGrace must not be negative")
+    }
+    if c.Server.PingInterval.Duration <= 0 {
+        problems = append(problems, "server.pingInterval must be positive")
+    }
+    // A healthy connection has to get at least one pong in before the deadline
+    if c.Server.ReadTimeout.Duration <= c.Server.PingInterval.Duration {
+        problems = append(problems, "server.readTimeout must be longer than server.pingInterval")
+    }
+    if c.Server.AFKTimeout.Duration < 0 {
+        problems = append(problems, "server.afkTimeout must not be negative")
+    }
+    switch c.Database.Driver {
+    case "mysql", "sqlite":
+        if c.Database.DSN == "" {
+            problems = append(problems, fmt.Sprintf("database.dsn is required for the %s driver", c.Database.Driver))
+        }
+    case "memory":
+    default:
+        problems = append(problems, fmt.Sprintf("database.driver %q must be mysql, sqlite or memory", c.Database.Driver))
+    }
+    // The water layer takes the middle third of the map, anything smaller has no room to fish
+    if c.World.Width < 9 || c.World.Height < 9 {
+        problems = append(problems, "world.width and world.height must be at least 9")
+    }
+    if c.World.ViewRadius < 1 {
+        problems = append(problems, "world.viewRadius must be at least 1")
+    }
+    if c.Fishing.BiteChance < 0 || c.Fishing.BiteChance > 1 {
+        problems = append(problems, "fishing.biteChance must be between 0 and 1")
+    }
+    if c.Fishing.MinWait.Duration < 0 || c.Fishing.MaxWait.Duration < c.Fishing.MinWait.Duration {
+        problems = append(problems, "fishing.minWait must be non-negative and not above fishing.maxWait")
+    }
+    if c.Fishing.CatchWindow.Duration <= 0 {
+        problems = append(problems, "fishing.catchWindow must be positive")
+    }
+    if c.Fishing.CastTime.Duration < 0 || c.Fishing.ReelGrace.Duration < 0 {
+        problems = append(problems, "fishing.castTime and fishing.reelGrace must not be negative")
+    }
+    // Otherwise nobody could ever land a fish with bare hands
+    if c.Fishing.MinReaction.Duration < 0 || c.Fishing.MinReaction.Duration >= c.Fishing.CatchWindow.Duration {
+        problems = append(problems, "fishing.minReaction must be non-negative and shorter than fishing.catchWindow")
+    }
+    if c.Inventory.Capacity < 1 {
+        problems = append(problems, "inventory.capacity must be at least 1")
+    }
+    if c.Auth.TokenTTL.Duration <= 0 {
+        problems = append(problems, "auth.tokenTTL must be positive")
+    }
+    if c.Auth.TokenSecret != "" && len(c.Auth.TokenSecret) < 16 {
+        problems = append(problems, "auth.tokenSecret must be at least 16 characters")
+    }
+    if c.Auth.TokenSecret == exampleTokenSecret {
+        problems = append(problems, "auth.tokenSecret is the placeholder from the example config, set a random secret of your own")
+    }
+    if _, ok := c.RateLimit.Messages["default"]; !ok {
+        problems = append(problems, "rateLimit.messages needs a \"default\" entry")
+    }
+    msgTypes := make([]string, 0, len(c.RateLimit.Messages))
+    for msgType := range c.RateLimit.Messages {
+        msgTypes = append(msgTypes, msgType)
+    }
+    sort.Strings(msgTypes)
+    for _, msgType := range msgTypes {
+        if limit := c.RateLimit.Messages[msgType]; limit.Rate <= 0 || limit.Burst < 1 {
+            problems = append(problems, fmt.Sprintf("rateLimit.messages.%s needs a positive rate and a burst of at least 1", msgType))
+        }
+    }
+    if c.RateLimit.MaxStrikes < 1 {
+        problems = append(problems, "rateLimit.maxStrikes must be at least 1")
+    }
+    if c.RateLimit.StrikeWindow.Duration <= 0 {
+        problems = append(problems, "rateLimit.strikeWindow must be positive")
+    }
+    if c.RateLimit.Auth.Rate <= 0 || c.RateLimit.Auth.Burst < 1 {
+        problems = append(problems, "rateLimit.auth needs a positive rate and a burst of at least 1")
+    }
+    if len(problems) > 0 {
+        return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+    }
+    return nil
+}
+
+// **Redacted**
+// Returns a copy that is safe to log, with database passwords masked.
+func (c Config) redacted() Config {
+    c.Server.AllowedOrigins = append([]string(nil), c.Server.AllowedOrigins...)
+    c.Database.DSN = redactDSN(c.Database.DSN)
+    if c.Auth.TokenSecret != "" {
+        c.Auth.TokenSecret = "****"
+    }
+    return c
+}
+
+// **Redact DSN**
+// Masks the password in a "user:password@host" style DSN.
+func redactDSN(dsn string) string {
+    at := strings.LastIndex(dsn, "@")
+    if at < 0 {
+        return dsn
+    }
+    colon := strings.Index(dsn[:at], ":")
+    if colon < 0 {
+        return dsn
+    }
+    return dsn[:colon+1] + "****" + dsn[at:]
+}
+
+// **Log Config**
+// Prints the effective configuration at startup.
+func logConfig(c Config) {
+    out, err := json.MarshalIndent(c.redacted(), "", "  ")
+    if err != nil {
+        ErrorLogger.Printf("Failed to print configuration: %v", err)
+        return
+    }
+    InfoLogger.Printf("Effective configuration:\n%s", out)
+}
+
+// **Check Origin**
+// Allows WebSocket upgrades and logins only from the configured origins.
+func checkOrigin(origin string) bool {
+    for _, allowed := range config.Server.AllowedOrigins {
+        if allowed == "*" || strings.EqualFold(allowed, origin) {
+            return true
+        }
+    }
+    return false
+}
+
+// **Allows Any Origin**
+// Whether `server.allowedOrigins` lets every site in.
+func allowsAnyOrigin() bool {
+    for _, allowed := range config.Server.AllowedOrigins {
+        if allowed == "*" {
+            return true
+        }
+    }
+    return false
+}
+
+// **Apply Fishing Config**
+// Uses the configured tuning as the bare hands baseline for fishing.
+func applyFishingConfig(c FishingConfig) {
+    bareHands.BiteChance = c.BiteChance
+    bareHands.MinWait = c.MinWait.Duration
+    bareHands.MaxWait = c.MaxWait.Duration
+    bareHands.CatchWindow = c.CatchWindow.Duration
+}
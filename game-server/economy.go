@@ -0,0 +1,130 @@
+package main
+
+import (
+    "fmt"
+)
+
+// **Trade Structure**
+// The result of an economy operation, persisted atomically by `PlayerStore.ApplyTrade`.
+type Trade struct {
+    Player  *Player // Player state after the trade; its row is written as a whole
+    Changed []Item  // Inventory rows after the trade by UID, a quantity of 0 deletes the row
+}
+
+// **Trade Failed Error**
+// Returned when the player store couldn't persist a trade. Nothing was changed.
+type TradeFailedError struct {
+    Err error
+}
+
+func (e *TradeFailedError) Error() string {
+    return fmt.Sprintf("trade failed, nothing was changed: %v", e.Err)
+}
+
+func (e *TradeFailedError) Unwrap() error {
+    return e.Err
+}
+
+// **Commit Trade**
+// Persists the trade and, only once that succeeds, copies the new state onto the live player.
+// Caller must hold `mu`.
+func commitTrade(player *Player, after *Player, changed []Item) error {
+    err := writePlayerLocked(player, func() error {
+        return store.ApplyTrade(Trade{Player: after, Changed: changed})
+    })
+    if err != nil {
+        ErrorLogger.Printf("Failed to persist trade for player %s: %v", player.ID, err)
+        return &TradeFailedError{Err: err}
+    }
+    player.Balance = after.Balance
+    player.Inventory = after.Inventory
+    player.EquippedRod = after.EquippedRod
+    return nil
+}
+
+// **Sell Item**
+// Removes `quantity` of the item from the player's inventory and credits what
+// it's worth. With a UID that exact item is sold, otherwise any with the
+// catalog ID, see `takeItems`. Returns the amount earned and the items sold.
+// Caller must hold `mu`.
+func sellItem(player *Player, entry CatalogItem, uid string, quantity int) (int, []Item, error) {
+    after := copyPlayer(player)
+    inventory, rows, taken, err := takeItems(after.Inventory, uid, entry.ID, quantity)
+    if err != nil {
+        return 0, nil, err
+    }
+    earned := entry.sellPrice(taken)
+    after.Inventory = inventory
+    after.Balance += earned
+
+    // Selling your last rod also takes it out of your hand
+    if after.EquippedRod == entry.ID && !hasItem(after, entry.ID) {
+        after.EquippedRod = ""
+    }
+
+    if err := commitTrade(player, after, rows); err != nil {
+        return 0, nil, err
+    }
+    return earned, taken, nil
+}
+
+// **Buy Item**
+// Debits the price from the player's balance and grants the item, failing with
+// `InsufficientFundsError` if they can't afford it or `ErrInventoryFull` if
+// there's no room for it. Caller must hold `mu`.
+func buyItem(player *Player, entry CatalogItem, quantity int) (int, error) {
+    price := entry.Value * quantity
+    if player.Balance < price {
+        return 0, &InsufficientFundsError{Required: price, Balance: player.Balance}
+    }
+
+    after := copyPlayer(player)
+    inventory, row, err := addItem(after.Inventory, entry.toItem(quantity))
+    if err != nil {
+        return 0, err
+    }
+    after.Inventory = inventory
+    after.Balance -= price
+
+    // A new rod is always an upgrade, put it straight in the player's hand
+    if entry.Type == "Pole" {
+        after.EquippedRod = entry.ID
+    }
+
+    if err := commitTrade(player, after, []Item{row}); err != nil {
+        return 0, err
+    }
+    return price, nil
+}
+
+// **Send Trade Result (Locked)**
+// Sends the player's inventory and balance followed by the trade event, all
+// tagged with the request ID. Caller must hold `mu`.
+func sendTradeResultLocked(req Request, event Message) {
+    player := req.Player
+    replies := []Message{
+        {Type: "inventoryUpdate", Player: player, Data: player.Inventory},
+        {Type: "playerUpdate", Player: player},
+        event,
+    }
+    for _, reply := range replies {
+        reply.RequestID = req.RequestID
+        if err := player.Send(reply); err != nil {
+            ErrorLogger.Printf("Error sending %s to player %s: %v", reply.Type, player.ID, err)
+            return
+        }
+    }
+}
+
+// **Replay Trade (Locked)**
+// Answers a retried economy request from the reply cache instead of applying it
+// again. Reports whether the request was a retry. Caller must hold `mu`.
+func replayTradeLocked(req Request) bool {
+    event, ok := recallReplyLocked(req)
+    if !ok {
+        return false
+    }
+    DebugLogger.Printf("Player %s retried %s request %s, replaying the result", req.Player.ID, req.Type, req.RequestID)
+    sendTradeResultLocked(req, event)
+    return true
+}
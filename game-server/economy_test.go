@@ -0,0 +1,172 @@
+package main
+
+import (
+    "errors"
+    "reflect"
+    "strconv"
+    "testing"
+)
+
+func TestSellItem(t *testing.T) {
+    fishValue := itemCatalog["commonfish"].Value
+    rodValue := itemCatalog["rod-half-decent"].Value
+    tests := []struct {
+        name     string
+        itemID   string
+        uid      string
+        quantity int
+        earned   int
+        sold     []string // "uid:quantity" of the items sold
+        fishLeft int
+        equipped string
+        wantErr  bool
+    }{
+        {name: "part of a stack", itemID: "commonfish", quantity: 5, earned: 5 * fishValue, sold: []string{"fish-1:5"}, fishLeft: 7, equipped: "rod-half-decent"},
+        {name: "whole stack by uid", itemID: "commonfish", uid: "fish-1", quantity: 12, earned: 12 * fishValue, sold: []string{"fish-1:12"}, equipped: "rod-half-decent"},
+        {name: "rod in hand", itemID: "rod-half-decent", uid: "rod-1", quantity: 1, earned: rodValue, sold: []string{"rod-1:1"}, fishLeft: 12},
+        {name: "more than owned", itemID: "commonfish", quantity: 13, fishLeft: 12, equipped: "rod-half-decent", wantErr: true},
+        {name: "not owned", itemID: "redfish", quantity: 1, fishLeft: 12, equipped: "rod-half-decent", wantErr: true},
+    }
+    for storeName, open := range testStores {
+        for _, tt := range tests {
+            t.Run(storeName+"/"+tt.name, func(t *testing.T) {
+                useStore(t, open(t))
+                player := savedTestPlayer(t, 100)
+
+                earned, sold, err := sellItem(player, itemCatalog[tt.itemID], tt.uid, tt.quantity)
+                if (err != nil) != tt.wantErr {
+                    t.Fatalf("sellItem error = %v, want error %v", err, tt.wantErr)
+                }
+                if earned != tt.earned {
+                    t.Errorf("earned %d, want %d", earned, tt.earned)
+                }
+                var soldQuantities []string
+                for _, part := range sold {
+                    soldQuantities = append(soldQuantities, part.UID+":"+strconv.Itoa(part.Quantity))
+                }
+                if !reflect.DeepEqual(soldQuantities, tt.sold) {
+                    t.Errorf("sold %v, want %v", soldQuantities, tt.sold)
+                }
+                for _, p := range []*Player{player, loadTestPlayer(t, player.ID)} {
+                    if p.Balance != 100+tt.earned {
+                        t.Errorf("balance %d, want %d", p.Balance, 100+tt.earned)
+                    }
+                    if got := quantityOf(p, "commonfish"); got != tt.fishLeft {
+                        t.Errorf("%d Commonfish left, want %d", got, tt.fishLeft)
+                    }
+                    if p.EquippedRod != tt.equipped {
+                        t.Errorf("equipped rod %q, want %q", p.EquippedRod, tt.equipped)
+                    }
+                }
+            })
+        }
+    }
+}
+
+func TestBuyItem(t *testing.T) {
+    fishValue := itemCatalog["commonfish"].Value
+    rodValue := itemCatalog["rod-solid"].Value
+    tests := []struct {
+        name     string
+        itemID   string
+        quantity int
+        balance  int
+        capacity int
+        price    int
+        fish     int
+        equipped string
+        wantErr  error
+    }{
+        {name: "joins the stack", itemID: "commonfish", quantity: 3, balance: 1000, price: 3 * fishValue, fish: 15, equipped: "rod-half-decent"},
+        {name: "rod goes in hand", itemID: "rod-solid", quantity: 1, balance: rodValue, price: rodValue, fish: 12, equipped: "rod-solid"},
+        {name: "can't afford", itemID: "rod-solid", quantity: 1, balance: rodValue - 1, fish: 12, equipped: "rod-half-decent", wantErr: &InsufficientFundsError{}},
+        {name: "stack with a full inventory", itemID: "commonfish", quantity: 1, balance: 1000, capacity: 2, price: fishValue, fish: 13, equipped: "rod-half-decent"},
+        {name: "rod with a full inventory", itemID: "rod-solid", quantity: 1, balance: rodValue, capacity: 2, fish: 12, equipped: "rod-half-decent", wantErr: ErrInventoryFull},
+    }
+    for storeName, open := range testStores {
+        for _, tt := range tests {
+            t.Run(storeName+"/"+tt.name, func(t *testing.T) {
+                useStore(t, open(t))
+                if tt.capacity > 0 {
+                    capacity := config.Inventory.Capacity
+                    config.Inventory.Capacity = tt.capacity
+                    defer func() { config.Inventory.Capacity = capacity }()
+                }
+                player := savedTestPlayer(t, tt.balance)
+
+                price, err := buyItem(player, itemCatalog[tt.itemID], tt.quantity)
+                switch want := tt.wantErr.(type) {
+                case nil:
+                    if err != nil {
+                        t.Fatalf("buyItem error = %v", err)
+                    }
+                case *InsufficientFundsError:
+                    if !errors.As(err, &want) {
+                        t.Fatalf("buyItem error = %v, want InsufficientFundsError", err)
+                    }
+                default:
+                    if !errors.Is(err, want) {
+                        t.Fatalf("buyItem error = %v, want %v", err, want)
+                    }
+                }
+                if price != tt.price {
+                    t.Errorf("price %d, want %d", price, tt.price)
+                }
+                for _, p := range []*Player{player, loadTestPlayer(t, player.ID)} {
+                    if p.Balance != tt.balance-tt.price {
+                        t.Errorf("balance %d, want %d", p.Balance, tt.balance-tt.price)
+                    }
+                    if got := quantityOf(p, "commonfish"); got != tt.fish {
+                        t.Errorf("%d Commonfish, want %d", got, tt.fish)
+                    }
+                    if p.EquippedRod != tt.equipped {
+                        t.Errorf("equipped rod %q, want %q", p.EquippedRod, tt.equipped)
+                    }
+                }
+            })
+        }
+    }
+}
+
+// When the store can't save a trade the live player must be left exactly as it was.
+func TestFailedTradeChangesNothing(t *testing.T) {
+    trades := []struct {
+        name  string
+        trade func(player *Player) error
+    }{
+        {"sell by id", func(p *Player) error { _, _, err := sellItem(p, itemCatalog["commonfish"], "", 5); return err }},
+        {"sell rod in hand", func(p *Player) error { _, _, err := sellItem(p, itemCatalog["rod-half-decent"], "rod-1", 1); return err }},
+        {"buy", func(p *Player) error { _, err := buyItem(p, itemCatalog["rod-solid"], 1); return err }},
+        {"repair", func(p *Player) error { _, _, err := repairRod(p, 0); return err }},
+        {"upgrade", func(p *Player) error { _, _, err := upgradeRod(p, 0, "reelSpeed"); return err }},
+    }
+    for storeName, open := range testStores {
+        for _, tt := range trades {
+            t.Run(storeName+"/"+tt.name, func(t *testing.T) {
+                useStore(t, open(t))
+                player := savedTestPlayer(t, 5000)
+                worn := *player.Inventory[0].Rod
+                worn.Durability = 1
+                player.Inventory[0].Rod = &worn
+                if err := store.SavePlayer(player); err != nil {
+                    t.Fatalf("save player: %v", err)
+                }
+                saved := loadTestPlayer(t, player.ID)
+                useStore(t, failingStore{store})
+
+                before := copyPlayer(player)
+                err := tt.trade(player)
+                var tradeErr *TradeFailedError
+                if !errors.As(err, &tradeErr) || !errors.Is(err, errStoreDown) {
+                    t.Fatalf("got %v, want a TradeFailedError wrapping the store's", err)
+                }
+                if got := copyPlayer(player); !reflect.DeepEqual(got, before) {
+                    t.Errorf("player changed by the failed trade:\n got %+v\nwant %+v", got, before)
+                }
+                if got := loadTestPlayer(t, player.ID); !reflect.DeepEqual(got, saved) {
+                    t.Errorf("saved player changed by the failed trade:\n got %+v\nwant %+v", got, saved)
+                }
+            })
+        }
+    }
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+    "fmt"
+    "math/rand"
+    "time"
+)
+
+// **Fishing State**
+// Where a player's cast is. Sessions only move forward:
+// idle → casting → waiting → bite → reeling → resolved, dropping to resolved
+// early when nothing bites, the fish escapes, the line snaps or the player
+// gives it away.
+type fishingState int
+
+const (
+    fishingIdle     fishingState = iota // No line out, players without a session are idle
+    fishingCasting                      // Line in the air
+    fishingWaiting                      // Line in the water, waiting for a bite
+    fishingBite                         // Fish on the line, catch window open
+    fishingReeling                      // Hooked, the reel game is on
+    fishingResolved                     // Landed or lost, the session is over
+)
+
+func (s fishingState) String() string {
+    switch s {
+    case fishingIdle:
+        return "idle"
+    case fishingCasting:
+        return "casting"
+    case fishingWaiting:
+        return "waiting"
+    case fishingBite:
+        return "bite"
+    case fishingReeling:
+        return "reeling"
+    case fishingResolved:
+        return "resolved"
+    }
+    return "unknown"
+}
+
+// **Fishing Fail Reasons**
+// The `reason` of a "fail" fishing event.
+const (
+    FishingFailNothingBit    = "nothingBit"    // The wait ended without a bite
+    FishingFailEscaped       = "escaped"       // The catch window closed or the fight ran out of time
+    FishingFailTooEarly      = "tooEarly"      // Reeled before anything bit, scaring the fish off
+    FishingFailTooFast       = "tooFast"       // Reacted to the bite faster than a person can
+    FishingFailMoved         = "moved"         // Walked away from the line
+    FishingFailLineSnapped   = "lineSnapped"   // Reeled against the fish until the line broke
+    FishingFailInventoryFull = "inventoryFull" // Landed the fish with nowhere to put it, so it went back
+)
+
+// **Fishing Sessions Map**
+// Each player's cast from the cast until it's resolved. Only touched by the game loop.
+var fishingSessions = make(map[string]*fishingSession)
+
+// **Fishing Session Structure**
+// A cast in progress and the timer that will advance it.
+type fishingSession struct {
+    state          fishingState
+    player         *Player   // Whose line it is
+    rod            RodStats  // Rod stats captured when the line was cast
+    rodUID         string    // Instance ID of the rod cast with, worn as it's used
+    timer          *timer    // Moves the session out of its current state
+    requestID      string    // ID of the `fish` request, echoed in the bite and fail events
+    catchRequestID string    // ID of the accepted `catchAttempt`, echoed when the fish lands
+    biteAt         time.Time // When the bite was sent, to check reaction times against
+    reel           *reelGame // The fight, once a fish is hooked
+}
+
+// **Fishing State Of**
+// The state of the player's cast.
+func fishingStateOf(playerID string) fishingState {
+    if session, ok := fishingSessions[playerID]; ok {
+        return session.state
+    }
+    return fishingIdle
+}
+
+// **Advance Fishing**
+// Moves the session to the next state and schedules what happens after
+// `delay`. Timers from earlier states find the session moved on and do nothing.
+func advanceFishing(session *fishingSession, to fishingState, delay time.Duration, next func()) {
+    session.timer.cancel()
+    session.state = to
+    session.timer = schedule(delay, next)
+}
+
+// **Handle Fishing**
+// Casts the player's line if they're facing water and not fishing already.
+func handleFishing(req Request) {
+    player := req.Player
+    if state := fishingStateOf(player.ID); state != fishingIdle {
+        DebugLogger.Printf("Player %s tried to cast while %s", player.ID, state)
+        sendError(player, req.RequestID, ErrCodeAlreadyFishing, "Your line is already out!")
+        return
+    }
+
+    facingX, facingY := getFacingTile(player)
+    DebugLogger.Printf("Player %s attempting to fish at (%d, %d)", player.ID, facingX, facingY)
+    if !isWithinBounds(facingX, facingY) || gameMap[facingY][facingX].Type != 0 {
+        DebugLogger.Printf("Invalid fishing attempt by player %s", player.ID)
+        sendError(player, req.RequestID, ErrCodeCantFishHere, "You can't fish here!")
+        return
+    }
+
+    // Every catch is unique and needs a slot of its own
+    mu.Lock()
+    rod := equippedRodStats(player)
+    rodItem, _ := equippedRod(player)
+    if len(player.Inventory) >= config.Inventory.Capacity {
+        sendError(player, req.RequestID, ErrCodeInventoryFull, "Your inventory is full, sell something first")
+        mu.Unlock()
+        return
+    }
+    if rodItem.Rod.broken() {
+        sendError(player, req.RequestID, ErrCodeRodBroken, fmt.Sprintf("Your %s is broken, get it repaired first", rodItem.Name))
+        mu.Unlock()
+        return
+    }
+
+    DebugLogger.Printf("Starting fishing process for player %s (rod level %d)", player.ID, rod.Level)
+    session := &fishingSession{player: player, rod: rod, rodUID: rodItem.UID, requestID: req.RequestID}
+    fishingSessions[player.ID] = session
+    advanceFishing(session, fishingCasting, config.Fishing.CastTime.Duration, func() {
+        lineLanded(player, session)
+    })
+
+    // Casting wears the rod, the cast that breaks it still goes out
+    worn := rodItem.Rod != nil
+    var snapshot playerSnapshot
+    if worn {
+        broke := wearRodLocked(player, rodItem.UID, castWear)
+        player.Send(Message{Type: "inventoryUpdate", Player: player, Data: player.Inventory, RequestID: req.RequestID})
+        if broke {
+            sendRodBrokenLocked(player, rodItem.UID, req.RequestID)
+        }
+        snapshot = snapshotPlayerLocked(player)
+    }
+    sendAck(player, req.RequestID)
+    mu.Unlock()
+
+    if worn {
+        if err := saveSnapshot(snapshot); err != nil {
+            ErrorLogger.Printf("Failed to save rod wear for player %s: %v", player.ID, err)
+        }
+    }
+}
+
+// **Line Landed**
+// The cast is done and the wait for a bite begins.
+func lineLanded(player *Player, session *fishingSession) {
+    if session.state != fishingCasting {
+        return
+    }
+    advanceFishing(session, fishingWaiting, session.rod.waitTime(), func() {
+        fishBite(player, session)
+    })
+}
+
+// **Fish Bite**
+// Runs when the wait is over: either a fish bites and the catch window opens, or nothing bites.
+func fishBite(player *Player, session *fishingSession) {
+    if session.state != fishingWaiting {
+        return
+    }
+    rod := session.rod
+    if rand.Float64() > rod.BiteChance {
+        DebugLogger.Printf("No fish bite for player %s", player.ID)
+        resolveFishing(player, session, FishingFailNothingBit)
+        return
+    }
+
+    DebugLogger.Printf("Fish bite for player %s", player.ID)
+    session.biteAt = time.Now()
+    advanceFishing(session, fishingBite, rod.CatchWindow, func() {
+        if session.state == fishingBite {
+            DebugLogger.Printf("Player %s failed to catch fish (timeout)", player.ID)
+            resolveFishing(player, session, FishingFailEscaped)
+        }
+    })
+
+    mu.Lock()
+    defer mu.Unlock()
+    biteMessage := Message{
+        Type:      "fishingEvent",
+        Player:    player,
+        RequestID: session.requestID,
+        Data: FishingEventData{
+            Event:         "start",
+            PlayerID:      player.ID,
+            CatchWindowMs: rod.CatchWindow.Milliseconds(),
+        },
+    }
+    player.Send(biteMessage)
+}
+
+// **Handle Catch Attempt**
+// Hooks the fish if one is biting and starts the reel game with it. Reeling
+// before the bite scares the fish off, and so does reacting faster than
+// `MinReaction`, which only a script can. Reaction time counts from sending
+// the bite to receiving the attempt, so latency only ever makes it look slower.
+func handleCatchAttempt(req Request) {
+    player := req.Player
+    session, ok := fishingSessions[player.ID]
+    if !ok {
+        sendError(player, req.RequestID, ErrCodeNotBiting, "Nothing is biting")
+        return
+    }
+
+    switch session.state {
+    case fishingCasting, fishingWaiting:
+        DebugLogger.Printf("Player %s reeled in while %s", player.ID, session.state)
+        sendError(player, req.RequestID, ErrCodeNotBiting, "Nothing is biting, and now you've scared the fish off")
+        resolveFishing(player, session, FishingFailTooEarly)
+    case fishingBite:
+        reaction := req.Received.Sub(session.biteAt)
+        if reaction < config.Fishing.MinReaction.Duration {
+            WarningLogger.Printf("Player %s reacted to a bite in %v, rejecting as automated", player.ID, reaction)
+            sendError(player, req.RequestID, ErrCodeTooFast, "That was faster than humanly possible")
+            resolveFishing(player, session, FishingFailTooFast)
+            return
+        }
+        fish := selectRandomFish(session.rod)
+        DebugLogger.Printf("Player %s hooked a %s after %v", player.ID, fish.Name, reaction)
+        now := time.Now()
+        session.catchRequestID = req.RequestID
+        session.reel = newReelGame(fish, session.rod, now)
+        advanceFishing(session, fishingReeling, session.reel.deadline.Sub(now), func() {
+            if session.state == fishingReeling {
+                DebugLogger.Printf("Player %s ran out of time reeling in a %s", player.ID, fish.Name)
+                resolveFishing(player, session, FishingFailEscaped)
+            }
+        })
+        sendFishingEvent(player, req.RequestID, FishingEventData{
+            Event:    "hooked",
+            PlayerID: player.ID,
+            Reel:     session.reel.params(),
+        })
+    default:
+        sendError(player, req.RequestID, ErrCodeNotBiting, "Nothing is biting")
+    }
+}
+
+// **Reel In**
+// The fight is won and the hooked fish is landed.
+func reelIn(player *Player, session *fishingSession) {
+    if session.state != fishingReeling {
+        return
+    }
+    session.timer.cancel()
+    session.state = fishingResolved
+    delete(fishingSessions, player.ID)
+    landFish(player, session.reel.fish, session.rod, session.rodUID, session.catchRequestID)
+}
+
+// **Resolve Fishing**
+// Ends the session without a catch and tells the player why.
+func resolveFishing(player *Player, session *fishingSession, reason string) {
+    session.timer.cancel()
+    session.state = fishingResolved
+    if fishingSessions[player.ID] == session {
+        delete(fishingSessions, player.ID)
+    }
+    sendFishingEvent(player, session.requestID, FishingEventData{
+        Event:    "fail",
+        PlayerID: player.ID,
+        Reason:   reason,
+    })
+}
+
+// **Abandon Fishing**
+// Ends the player's cast, if they have one, because they walked away from it
+// or left the game. A cast by a newer player with the same ID is left alone.
+func abandonFishing(player *Player) {
+    if session, ok := fishingSessions[player.ID]; ok && session.player == player {
+        DebugLogger.Printf("Player %s left their line while %s", player.ID, session.state)
+        resolveFishing(player, session, FishingFailMoved)
+    }
+}
+
+// **Land Fish**
+// Rolls the caught fish's size and quality, adds it to the player's inventory
+// and tells them what they caught and whether it's a personal record. The rod
+// with `rodUID` wears by how hard the fish fought, whether or not it's kept.
+// The player is saved after `mu` is released.
+func landFish(player *Player, caughtFish Fish, rod RodStats, rodUID string, requestID string) {
+    catch := rollCatch(caughtFish, rod, time.Now())
+    DebugLogger.Printf("Player %s caught a %s %s (%.1fcm, %.3fkg)", player.ID, catch.Quality, caughtFish.Name, catch.Length, catch.Weight)
+
+    mu.Lock()
+    snapshot := landFishLocked(player, caughtFish, catch, rodUID, requestID)
+    mu.Unlock()
+
+    if err := saveSnapshot(snapshot); err != nil {
+        ErrorLogger.Printf("Failed to save catch for player %s: %v", player.ID, err)
+    }
+}
+
+// **Land Fish (Locked)**
+// Applies the catch and the rod's wear and sends the results, returning the
+// player to save. Caller must hold `mu`.
+func landFishLocked(player *Player, caughtFish Fish, catch Catch, rodUID string, requestID string) playerSnapshot {
+
+    if wearRodLocked(player, rodUID, landingWear(caughtFish)) {
+        sendRodBrokenLocked(player, rodUID, requestID)
+    }
+
+    catchEntry, _ := lookupCatalogItem("", caughtFish.Name)
+    item := catchEntry.toItem(1)
+    item.Catch = &catch
+    if err := addItemToInventoryLocked(player, item); err != nil {
+        DebugLogger.Printf("Player %s had no room for their %s", player.ID, caughtFish.Name)
+        player.Send(Message{
+            Type:      "fishingEvent",
+            Player:    player,
+            RequestID: requestID,
+            Data: FishingEventData{
+                Event:    "fail",
+                PlayerID: player.ID,
+                Reason:   FishingFailInventoryFull,
+            },
+        })
+        return snapshotPlayerLocked(player)
+    }
+    record := recordCatchLocked(player, catchEntry.ID, catch)
+
+    inventoryMessage := Message{
+        Type:      "inventoryUpdate",
+        Player:    player,
+        Data:      player.Inventory,
+        RequestID: requestID,
+    }
+    player.Send(inventoryMessage)
+
+    catchMessage := Message{
+        Type:      "fishingEvent",
+        Player:    player,
+        RequestID: requestID,
+        Data: FishingEventData{
+            Event:    "catch",
+            PlayerID: player.ID,
+            Fish:     &caughtFish,
+            Catch:    &catch,
+            Price:    catch.price(catchEntry.ID, catchEntry.Value),
+            Record:   record,
+        },
+    }
+    player.Send(catchMessage)
+    return snapshotPlayerLocked(player)
+}
+
+// **Send Fishing Event**
+// Sends the player a `fishingEvent`.
+func sendFishingEvent(player *Player, requestID string, event FishingEventData) {
+    mu.Lock()
+    defer mu.Unlock()
+    player.Send(Message{
+        Type:      "fishingEvent",
+        Player:    player,
+        RequestID: requestID,
+        Data:      event,
+    })
+}
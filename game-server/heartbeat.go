@@ -0,0 +1,68 @@
+package main
+
+import (
+    "errors"
+    "net"
+    "time"
+
+    "github.com/gorilla/websocket"
+)
+
+// **Extend Read Deadline**
+// Gives the client another `ReadTimeout` to be heard from. A connection that
+// goes quiet for longer, e.g. a half-open TCP connection, fails its next read,
+// which ends the read loop and saves or detaches the player.
+func extendReadDeadline(ws *websocket.Conn) {
+    ws.SetReadDeadline(time.Now().Add(config.Server.ReadTimeout.Duration))
+}
+
+// **Watch Heartbeat**
+// Counts pongs to the writer's pings as signs of life. Browsers answer pings
+// on their own, so an idle but healthy client stays connected.
+func watchHeartbeat(ws *websocket.Conn) {
+    extendReadDeadline(ws)
+    ws.SetPongHandler(func(string) error {
+        extendReadDeadline(ws)
+        return nil
+    })
+}
+
+// **Is Timeout**
+// Whether a read failed because the client stopped answering.
+func isTimeout(err error) bool {
+    var netErr net.Error
+    return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// **Note Activity (Locked)**
+// Records that the player did something, clearing their AFK flag. Caller must hold `mu`.
+func noteActivityLocked(player *Player, now time.Time) {
+    player.lastActive = now
+    if player.AFK {
+        player.AFK = false
+        markDirtyLocked(player.ID)
+        DebugLogger.Printf("Player %s is back", player.ID)
+    }
+}
+
+// **Detect AFK**
+// Tick system that flags connected players who haven't sent anything for
+// `AFKTimeout`. Checks once a second, which is plenty at that timescale.
+func detectAFK(tick uint64, now time.Time) {
+    afkTimeout := config.Server.AFKTimeout.Duration
+    if afkTimeout == 0 || tick%uint64(config.Server.TickRate) != 0 {
+        return
+    }
+
+    mu.Lock()
+    defer mu.Unlock()
+    for id, p := range players {
+        // Disconnected players are already shown as idle
+        if p.AFK || p.Idle || now.Sub(p.lastActive) < afkTimeout {
+            continue
+        }
+        p.AFK = true
+        markDirtyLocked(id)
+        DebugLogger.Printf("Player %s is AFK", id)
+    }
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "math/rand"
+    "sort"
+    "strings"
+    "time"
+)
+
+// **Inventory Full Error**
+// Returned when an item needs a free slot and the player has none.
+var ErrInventoryFull = errors.New("inventory is full")
+
+// **Inventory Sort Orders**
+// The `by` of a `sortInventory` request.
+var inventorySorts = map[string]func(a, b Item) bool{
+    "name":   func(a, b Item) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) },
+    "type":   func(a, b Item) bool { return a.Type < b.Type },
+    "value":  func(a, b Item) bool { return a.worth() > b.worth() },
+    "newest": func(a, b Item) bool { return a.AcquiredAt > b.AcquiredAt },
+}
+
+// **New Item UID**
+// A random instance ID for an item entering a player's inventory. Only needs
+// to be unique, unlike session tokens it isn't a secret.
+func newItemUID() string {
+    return fmt.Sprintf("%016x", rand.Uint64())
+}
+
+// **Stacks**
+// Whether the item merges with others of its kind. Only plain catalog goods
+// stack, anything with attributes of its own is a unique instance.
+func (i Item) stacks() bool {
+    entry, ok := itemCatalog[i.ID]
+    return ok && entry.Stackable && i.Catch == nil && i.Rod == nil
+}
+
+// **Worth**
+// What the whole item sells for. A worn rod is worth its share of durability
+// left, so selling a broken rod never beats repairing it.
+func (i Item) worth() int {
+    if i.Catch != nil {
+        return i.Catch.price(i.ID, i.Value) * i.Quantity
+    }
+    if i.Rod != nil && i.Rod.MaxDurability > 0 {
+        return i.Value * i.Rod.Durability / i.Rod.MaxDurability * i.Quantity
+    }
+    return i.Value * i.Quantity
+}
+
+// **Find Item**
+// Index of the item with the UID, or -1.
+func findItem(inventory []Item, uid string) int {
+    for i, invItem := range inventory {
+        if invItem.UID == uid {
+            return i
+        }
+    }
+    return -1
+}
+
+// **Add Item**
+// Returns a new inventory with the item added and the resulting inventory row.
+// Stackable items join the existing stack, anything else takes a slot of its
+// own, failing with `ErrInventoryFull` when there are none left.
+func addItem(inventory []Item, item Item) ([]Item, Item, error) {
+    updated := make([]Item, len(inventory))
+    copy(updated, inventory)
+    if item.stacks() {
+        for i, invItem := range updated {
+            if invItem.ID == item.ID && invItem.stacks() {
+                updated[i].Quantity += item.Quantity
+                return updated, updated[i], nil
+            }
+        }
+    }
+    if len(updated) >= config.Inventory.Capacity {
+        return nil, Item{}, ErrInventoryFull
+    }
+    if item.UID == "" {
+        item.UID = newItemUID()
+    }
+    if item.AcquiredAt == 0 {
+        item.AcquiredAt = time.Now().UnixMilli()
+    }
+    return append(updated, item), item, nil
+}
+
+// **Take Items**
+// Returns a new inventory with `quantity` of the item removed, the resulting
+// inventory rows and what was taken. With a UID only that item is taken from,
+// otherwise any items with the catalog ID are: stacks first, then the least
+// valuable instances, so trophies are the last to go. Rows with a quantity of
+// 0 were used up.
+func takeItems(inventory []Item, uid, id string, quantity int) ([]Item, []Item, []Item, error) {
+    var candidates []int
+    owned := 0
+    for i, invItem := range inventory {
+        if (uid != "" && invItem.UID == uid) || (uid == "" && invItem.ID == id) {
+            candidates = append(candidates, i)
+            owned += invItem.Quantity
+        }
+    }
+    if len(candidates) == 0 {
+        return nil, nil, nil, fmt.Errorf("item not found in inventory")
+    }
+    if owned < quantity {
+        return nil, nil, nil, fmt.Errorf("insufficient quantity: have %d, want to sell %d", owned, quantity)
+    }
+    sort.SliceStable(candidates, func(a, b int) bool {
+        first, second := inventory[candidates[a]], inventory[candidates[b]]
+        if first.stacks() != second.stacks() {
+            return first.stacks()
+        }
+        return first.worth()/first.Quantity < second.worth()/second.Quantity
+    })
+
+    updated := make([]Item, len(inventory))
+    copy(updated, inventory)
+    var rows, taken []Item
+    for _, i := range candidates {
+        if quantity == 0 {
+            break
+        }
+        n := updated[i].Quantity
+        if n > quantity {
+            n = quantity
+        }
+        part := updated[i]
+        part.Quantity = n
+        taken = append(taken, part)
+        updated[i].Quantity -= n
+        rows = append(rows, updated[i])
+        quantity -= n
+    }
+
+    kept := make([]Item, 0, len(updated))
+    for _, invItem := range updated {
+        if invItem.Quantity > 0 {
+            kept = append(kept, invItem)
+        }
+    }
+    return kept, rows, taken, nil
+}
+
+// **Handle Sort Inventory**
+// Reorders the player's inventory and saves the new order once `mu` is released.
+func handleSortInventory(req Request, sortReq *SortInventoryRequest) {
+    player := req.Player
+    mu.Lock()
+    less := inventorySorts[sortReq.By]
+    sorted := make([]Item, len(player.Inventory))
+    copy(sorted, player.Inventory)
+    sort.SliceStable(sorted, func(a, b int) bool { return less(sorted[a], sorted[b]) })
+    player.Inventory = sorted
+    player.Send(Message{
+        Type:      "inventoryUpdate",
+        Player:    player,
+        Data:      player.Inventory,
+        RequestID: req.RequestID,
+    })
+    snapshot := snapshotPlayerLocked(player)
+    mu.Unlock()
+
+    if err := saveSnapshot(snapshot); err != nil {
+        ErrorLogger.Printf("Failed to save sorted inventory for player %s: %v", player.ID, err)
+    }
+}
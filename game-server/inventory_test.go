@@ -0,0 +1,83 @@
+package main
+
+import (
+    "reflect"
+    "strconv"
+    "testing"
+)
+
+func TestTakeItems(t *testing.T) {
+    stack := itemCatalog["redfish"].toItem(3)
+    stack.UID = "stack"
+    small := itemCatalog["redfish"].toItem(1)
+    small.UID = "small"
+    small.Catch = &Catch{Weight: 0.1, Quality: "normal"}
+    trophy := itemCatalog["redfish"].toItem(1)
+    trophy.UID = "trophy"
+    trophy.Catch = &Catch{Weight: 50, Quality: "gold"}
+    rod := itemCatalog["rod-solid"].toItem(1)
+    rod.UID = "rod"
+    inventory := []Item{trophy, rod, small, stack}
+
+    tests := []struct {
+        name     string
+        uid      string
+        id       string
+        quantity int
+        kept     []string // UIDs left, in order
+        rows     []string // "uid:quantity" of the changed rows
+        wantErr  bool
+    }{
+        {name: "part of the stack", id: "redfish", quantity: 2, kept: []string{"trophy", "rod", "small", "stack"}, rows: []string{"stack:1"}},
+        {name: "stack before instances", id: "redfish", quantity: 3, kept: []string{"trophy", "rod", "small"}, rows: []string{"stack:0"}},
+        {name: "trophy goes last", id: "redfish", quantity: 4, kept: []string{"trophy", "rod"}, rows: []string{"stack:0", "small:0"}},
+        {name: "everything", id: "redfish", quantity: 5, kept: []string{"rod"}, rows: []string{"stack:0", "small:0", "trophy:0"}},
+        {name: "by uid", uid: "trophy", quantity: 1, kept: []string{"rod", "small", "stack"}, rows: []string{"trophy:0"}},
+        {name: "uid ignores the id", uid: "rod", id: "redfish", quantity: 1, kept: []string{"trophy", "small", "stack"}, rows: []string{"rod:0"}},
+        {name: "more than owned", id: "redfish", quantity: 6, wantErr: true},
+        {name: "more than the uid holds", uid: "stack", quantity: 4, wantErr: true},
+        {name: "not owned", id: "commonfish", quantity: 1, wantErr: true},
+        {name: "unknown uid", uid: "nope", quantity: 1, wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            original := make([]Item, len(inventory))
+            copy(original, inventory)
+
+            kept, rows, taken, err := takeItems(inventory, tt.uid, tt.id, tt.quantity)
+            if !reflect.DeepEqual(inventory, original) {
+                t.Fatal("takeItems changed the inventory it was given")
+            }
+            if tt.wantErr {
+                if err == nil {
+                    t.Fatal("takeItems succeeded, want an error")
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("takeItems error = %v", err)
+            }
+
+            var keptUIDs, rowQuantities []string
+            for _, invItem := range kept {
+                keptUIDs = append(keptUIDs, invItem.UID)
+            }
+            for _, row := range rows {
+                rowQuantities = append(rowQuantities, row.UID+":"+strconv.Itoa(row.Quantity))
+            }
+            if !reflect.DeepEqual(keptUIDs, tt.kept) {
+                t.Errorf("kept %v, want %v", keptUIDs, tt.kept)
+            }
+            if !reflect.DeepEqual(rowQuantities, tt.rows) {
+                t.Errorf("rows %v, want %v", rowQuantities, tt.rows)
+            }
+            total := 0
+            for _, part := range taken {
+                total += part.Quantity
+            }
+            if total != tt.quantity {
+                t.Errorf("took %d, want %d", total, tt.quantity)
+            }
+        })
+    }
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+    "database/sql"
+    "embed"
+    "fmt"
+    "io/fs"
+    "path"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// **Migration Files**
+// Versioned SQL migrations shipped inside the binary, one directory per dialect.
+// Files are named `NNNN_description.up.sql` and `NNNN_description.down.sql`.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// **Migration Structure**
+// A single schema change and how to undo it.
+type migration struct {
+    Version int
+    Name    string
+    Up      string
+    Down    string
+    Run     func(tx *sql.Tx) error // Instead of Up, for changes SQL can't make
+}
+
+// **Code Migrations**
+// Migrations written in Go, for every dialect. Their versions are shared with
+// the files, and a down that isn't needed is a comment.
+func codeMigrations(dialect sqlDialect) []migration {
+    return []migration{
+        {
+            Version: 6,
+            Name:    "split_legacy_catches",
+            Run:     func(tx *sql.Tx) error { return splitLegacyCatches(tx, dialect) },
+            Down:    "-- The split catches are items 0005 can hold as they are",
+        },
+    }
+}
+
+// **Migration Status Structure**
+// Whether a migration has been applied, as reported by `migrate status`.
+type migrationStatus struct {
+    migration
+    Applied   bool
+    AppliedAt string
+}
+
+// **Load Migrations**
+// Reads the embedded migrations for a dialect, sorted by version.
+func loadMigrations(dialect sqlDialect) ([]migration, error) {
+    dir := path.Join("migrations", dialect.migrationsDir)
+    entries, err := fs.ReadDir(migrationFiles, dir)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read migrations: %v", err)
+    }
+
+    byVersion := make(map[int]*migration)
+    for _, entry := range entries {
+        fileName := entry.Name()
+        var direction string
+        switch {
+        case strings.HasSuffix(fileName, ".up.sql"):
+            direction = "up"
+        case strings.HasSuffix(fileName, ".down.sql"):
+            direction = "down"
+        default:
+            continue
+        }
+
+        base := strings.TrimSuffix(fileName, "."+direction+".sql")
+        versionPart, name, _ := strings.Cut(base, "_")
+        version, err := strconv.Atoi(versionPart)
+        if err != nil {
+            return nil, fmt.Errorf("migration %s has no version number", fileName)
+        }
+
+        body, err := migrationFiles.ReadFile(path.Join(dir, fileName))
+        if err != nil {
+            return nil, fmt.Errorf("failed to read migration %s: %v", fileName, err)
+        }
+
+        m, ok := byVersion[version]
+        if !ok {
+            m = &migration{Version: version, Name: name}
+            byVersion[version] = m
+        }
+        if direction == "up" {
+            m.Up = string(body)
+        } else {
+            m.Down = string(body)
+        }
+    }
+
+    for _, code := range codeMigrations(dialect) {
+        if m, ok := byVersion[code.Version]; ok {
+            return nil, fmt.Errorf("migration %04d_%s has the version of %04d_%s", code.Version, code.Name, m.Version, m.Name)
+        }
+        m := code
+        byVersion[code.Version] = &m
+    }
+
+    migrations := make([]migration, 0, len(byVersion))
+    for _, m := range byVersion {
+        if m.Up == "" && m.Run == nil {
+            return nil, fmt.Errorf("migration %04d_%s is missing its up file", m.Version, m.Name)
+        }
+        migrations = append(migrations, *m)
+    }
+    sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+    return migrations, nil
+}
+
+// **Ensure Migrations Table**
+// Creates the table that records applied migrations.
+func ensureMigrationsTable(db *sql.DB) error {
+    _, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version    INTEGER NOT NULL PRIMARY KEY,
+            name       VARCHAR(255) NOT NULL,
+            applied_at VARCHAR(64) NOT NULL
+        )
+    `)
+    if err != nil {
+        return fmt.Errorf("failed to create schema_migrations table: %v", err)
+    }
+    return nil
+}
+
+// **Applied Migrations**
+// Returns the applied versions and when they were applied.
+func appliedMigrations(db *sql.DB) (map[int]string, error) {
+    rows, err := db.Query(`SELECT version, applied_at FROM schema_migrations`)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read schema_migrations: %v", err)
+    }
+    defer rows.Close()
+
+    applied := make(map[int]string)
+    for rows.Next() {
+        var version int
+        var appliedAt string
+        if err := rows.Scan(&version, &appliedAt); err != nil {
+            return nil, fmt.Errorf("failed to scan schema_migrations: %v", err)
+        }
+        applied[version] = appliedAt
+    }
+    return applied, rows.Err()
+}
+
+// **Split Statements**
+// Breaks a migration file into statements, since not every driver runs several per Exec.
+func splitStatements(script string) []string {
+    var statements []string
+    for _, stmt := range strings.Split(script, ";") {
+        var lines []string
+        for _, line := range strings.Split(stmt, "\n") {
+            if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+                lines = append(lines, line)
+            }
+        }
+        if len(lines) > 0 {
+            statements = append(statements, strings.Join(lines, "\n"))
+        }
+    }
+    return statements
+}
+
+// **Apply Migration**
+// Runs one direction of a migration and records the result in schema_migrations.
+func applyMigration(db *sql.DB, m migration, up bool) error {
+    script := m.Up
+    if !up {
+        if m.Down == "" {
+            return fmt.Errorf("migration %04d_%s can't be rolled back, it has no down file", m.Version, m.Name)
+        }
+        script = m.Down
+    }
+
+    // MySQL commits DDL implicitly, the transaction still keeps SQLite changes all-or-nothing
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    if up && m.Run != nil {
+        if err := m.Run(tx); err != nil {
+            return fmt.Errorf("migration %04d_%s failed: %v", m.Version, m.Name, err)
+        }
+    }
+    for _, stmt := range splitStatements(script) {
+        if _, err := tx.Exec(stmt); err != nil {
+            return fmt.Errorf("migration %04d_%s failed: %v", m.Version, m.Name, err)
+        }
+    }
+
+    if up {
+        _, err = tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+            m.Version, m.Name, time.Now().UTC().Format(time.RFC3339))
+    } else {
+        _, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+    }
+    if err != nil {
+        return fmt.Errorf("failed to record migration %04d_%s: %v", m.Version, m.Name, err)
+    }
+    return tx.Commit()
+}
+
+// **Migrate Up**
+// Applies every pending migration in order and returns how many ran.
+func migrateUp(db *sql.DB, dialect sqlDialect) (int, error) {
+    migrations, err := loadMigrations(dialect)
+    if err != nil {
+        return 0, err
+    }
+    if err := ensureMigrationsTable(db); err != nil {
+        return 0, err
+    }
+    applied, err := appliedMigrations(db)
+    if err != nil {
+        return 0, err
+    }
+
+    count := 0
+    for _, m := range migrations {
+        if _, ok := applied[m.Version]; ok {
+            continue
+        }
+        InfoLogger.Printf("Applying migration %04d_%s", m.Version, m.Name)
+        if err := applyMigration(db, m, true); err != nil {
+            return count, err
+        }
+        count++
+    }
+    return count, nil
+}
+
+// **Migrate Down**
+// Rolls back the most recent `steps` applied migrations and returns how many ran.
+func migrateDown(db *sql.DB, dialect sqlDialect, steps int) (int, error) {
+    migrations, err := loadMigrations(dialect)
+    if err != nil {
+        return 0, err
+    }
+    if err := ensureMigrationsTable(db); err != nil {
+        return 0, err
+    }
+    applied, err := appliedMigrations(db)
+    if err != nil {
+        return 0, err
+    }
+
+    count := 0
+    for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+        m := migrations[i]
+        if _, ok := applied[m.Version]; !ok {
+            continue
+        }
+        InfoLogger.Printf("Rolling back migration %04d_%s", m.Version, m.Name)
+        if err := applyMigration(db, m, false); err != nil {
+            return count, err
+        }
+        count++
+    }
+    return count, nil
+}
+
+// **Get Migration Status**
+// Lists every known migration and whether it has been applied.
+func getMigrationStatus(db *sql.DB, dialect sqlDialect) ([]migrationStatus, error) {
+    migrations, err := loadMigrations(dialect)
+    if err != nil {
+        return nil, err
+    }
+    if err := ensureMigrationsTable(db); err != nil {
+        return nil, err
+    }
+    applied, err := appliedMigrations(db)
+    if err != nil {
+        return nil, err
+    }
+
+    statuses := make([]migrationStatus, 0, len(migrations))
+    for _, m := range migrations {
+        appliedAt, ok := applied[m.Version]
+        statuses = append(statuses, migrationStatus{migration: m, Applied: ok, AppliedAt: appliedAt})
+    }
+    return statuses, nil
+}
+
+// **Run Migrate Command**
+// Handles `fishserver [flags] migrate up|down [steps]|status`.
+func runMigrateCommand(cfg Config, args []string) error {
+    var dialect sqlDialect
+    switch cfg.Database.Driver {
+    case "mysql":
+        dialect = mysqlDialect
+    case "sqlite":
+        dialect = sqliteDialect
+    default:
+        return fmt.Errorf("the %s store has no schema to migrate", cfg.Database.Driver)
+    }
+    if len(args) == 0 {
+        return fmt.Errorf("usage: fishserver [flags] migrate up|down [steps]|status")
+    }
+
+    db, err := openSQLDB(dialect, cfg.Database.DSN)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+
+    switch args[0] {
+    case "up":
+        count, err := migrateUp(db, dialect)
+        fmt.Printf("Applied %d migration(s)\n", count)
+        return err
+    case "down":
+        steps := 1
+        if len(args) > 1 {
+            if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+                return fmt.Errorf("steps must be a positive number, got %q", args[1])
+            }
+        }
+        count, err := migrateDown(db, dialect, steps)
+        fmt.Printf("Rolled back %d migration(s)\n", count)
+        return err
+    case "status":
+        statuses, err := getMigrationStatus(db, dialect)
+        if err != nil {
+            return err
+        }
+        for _, s := range statuses {
+            state := "pending"
+            if s.Applied {
+                state = "applied " + s.AppliedAt
+            }
+            fmt.Printf("%04d_%-40s %s\n", s.Version, s.Name, state)
+        }
+        return nil
+    default:
+        return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+    }
+}
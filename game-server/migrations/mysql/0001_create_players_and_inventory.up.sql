@@ -0,0 +1,19 @@
+-- Tables as they existed before migrations were managed by the server.
+CREATE TABLE IF NOT EXISTS players (
+    player_id    VARCHAR(64) NOT NULL PRIMARY KEY,
+    x            INT NOT NULL DEFAULT 0,
+    y            INT NOT NULL DEFAULT 0,
+    direction    VARCHAR(16) NOT NULL DEFAULT '',
+    facing_water BOOLEAN NOT NULL DEFAULT FALSE,
+    balance      INT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS inventory (
+    player_id VARCHAR(64) NOT NULL,
+    item_name VARCHAR(128) NOT NULL,
+    quantity  INT NOT NULL DEFAULT 0,
+    value     INT NOT NULL DEFAULT 0,
+    img       VARCHAR(255) NOT NULL DEFAULT '',
+    type      VARCHAR(32),
+    PRIMARY KEY (player_id, item_name)
+);
@@ -0,0 +1 @@
+ALTER TABLE players ADD COLUMN equipped_rod VARCHAR(64) NULL;
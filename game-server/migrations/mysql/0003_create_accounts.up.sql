@@ -0,0 +1,5 @@
+CREATE TABLE accounts (
+    player_id     VARCHAR(64) NOT NULL PRIMARY KEY,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at    VARCHAR(64) NOT NULL
+);
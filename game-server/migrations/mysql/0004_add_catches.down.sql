@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS catch_records;
+ALTER TABLE inventory DROP COLUMN catches;
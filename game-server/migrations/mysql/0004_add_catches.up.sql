@@ -0,0 +1,11 @@
+ALTER TABLE inventory ADD COLUMN catches TEXT NULL;
+
+CREATE TABLE IF NOT EXISTS catch_records (
+    player_id VARCHAR(64) NOT NULL,
+    fish_id   VARCHAR(128) NOT NULL,
+    length    DOUBLE NOT NULL,
+    weight    DOUBLE NOT NULL,
+    quality   VARCHAR(16) NOT NULL,
+    caught_at BIGINT NOT NULL,
+    PRIMARY KEY (player_id, fish_id)
+);
@@ -0,0 +1,18 @@
+-- Items of the same name collapse back into one stack, losing their attributes
+CREATE TABLE IF NOT EXISTS inventory (
+    player_id VARCHAR(64) NOT NULL,
+    item_name VARCHAR(128) NOT NULL,
+    quantity  INT NOT NULL DEFAULT 0,
+    value     INT NOT NULL DEFAULT 0,
+    img       VARCHAR(255) NOT NULL DEFAULT '',
+    type      VARCHAR(32),
+    catches   TEXT NULL,
+    PRIMARY KEY (player_id, item_name)
+);
+
+INSERT INTO inventory (player_id, item_name, quantity, value, img, type)
+SELECT player_id, item_name, SUM(quantity), MAX(value), MAX(img), MAX(type)
+FROM items
+GROUP BY player_id, item_name;
+
+DROP TABLE items;
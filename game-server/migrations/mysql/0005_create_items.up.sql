@@ -0,0 +1,22 @@
+-- Inventory rows keyed by item instance instead of by name
+CREATE TABLE IF NOT EXISTS items (
+    item_uid    VARCHAR(32) NOT NULL PRIMARY KEY,
+    player_id   VARCHAR(64) NOT NULL,
+    item_name   VARCHAR(128) NOT NULL,
+    quantity    INT NOT NULL DEFAULT 1,
+    value       INT NOT NULL DEFAULT 0,
+    img         VARCHAR(255) NOT NULL DEFAULT '',
+    type        VARCHAR(32),
+    attrs       TEXT NULL,
+    slot        INT NOT NULL DEFAULT 0,
+    acquired_at BIGINT NOT NULL DEFAULT 0,
+    INDEX items_player_id (player_id)
+);
+
+-- Stacked catches are split into an item each by 0006
+INSERT INTO items (item_uid, player_id, item_name, quantity, value, img, type, attrs)
+SELECT LOWER(HEX(RANDOM_BYTES(8))), player_id, item_name, quantity, value, img, type,
+       CASE WHEN catches IS NULL THEN NULL ELSE CONCAT('{"catches":', catches, '}') END
+FROM inventory;
+
+DROP TABLE inventory;
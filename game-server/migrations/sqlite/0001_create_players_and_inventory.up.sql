@@ -0,0 +1,22 @@
+-- Tables as the store created them before migrations were managed by the
+-- server. SQLite players always had equipped_rod, so unlike MySQL there is
+-- no 0002_add_equipped_rod.
+CREATE TABLE IF NOT EXISTS players (
+    player_id    TEXT PRIMARY KEY,
+    x            INTEGER NOT NULL DEFAULT 0,
+    y            INTEGER NOT NULL DEFAULT 0,
+    direction    TEXT NOT NULL DEFAULT '',
+    facing_water BOOLEAN NOT NULL DEFAULT 0,
+    balance      INTEGER NOT NULL DEFAULT 0,
+    equipped_rod TEXT
+);
+
+CREATE TABLE IF NOT EXISTS inventory (
+    player_id TEXT NOT NULL,
+    item_name TEXT NOT NULL,
+    quantity  INTEGER NOT NULL DEFAULT 0,
+    value     INTEGER NOT NULL DEFAULT 0,
+    img       TEXT NOT NULL DEFAULT '',
+    type      TEXT,
+    PRIMARY KEY (player_id, item_name)
+);
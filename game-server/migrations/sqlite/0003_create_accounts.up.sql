@@ -0,0 +1,5 @@
+CREATE TABLE accounts (
+    player_id     TEXT PRIMARY KEY,
+    password_hash TEXT NOT NULL,
+    created_at    TEXT NOT NULL
+);
@@ -0,0 +1,11 @@
+ALTER TABLE inventory ADD COLUMN catches TEXT;
+
+CREATE TABLE IF NOT EXISTS catch_records (
+    player_id TEXT NOT NULL,
+    fish_id   TEXT NOT NULL,
+    length    REAL NOT NULL,
+    weight    REAL NOT NULL,
+    quality   TEXT NOT NULL,
+    caught_at INTEGER NOT NULL,
+    PRIMARY KEY (player_id, fish_id)
+);
@@ -0,0 +1,18 @@
+-- Items of the same name collapse back into one stack, losing their attributes
+CREATE TABLE IF NOT EXISTS inventory (
+    player_id TEXT NOT NULL,
+    item_name TEXT NOT NULL,
+    quantity  INTEGER NOT NULL DEFAULT 0,
+    value     INTEGER NOT NULL DEFAULT 0,
+    img       TEXT NOT NULL DEFAULT '',
+    type      TEXT,
+    catches   TEXT,
+    PRIMARY KEY (player_id, item_name)
+);
+
+INSERT INTO inventory (player_id, item_name, quantity, value, img, type)
+SELECT player_id, item_name, SUM(quantity), MAX(value), MAX(img), MAX(type)
+FROM items
+GROUP BY player_id, item_name;
+
+DROP TABLE items;
@@ -0,0 +1,23 @@
+-- Inventory rows keyed by item instance instead of by name
+CREATE TABLE IF NOT EXISTS items (
+    item_uid    TEXT PRIMARY KEY,
+    player_id   TEXT NOT NULL,
+    item_name   TEXT NOT NULL,
+    quantity    INTEGER NOT NULL DEFAULT 1,
+    value       INTEGER NOT NULL DEFAULT 0,
+    img         TEXT NOT NULL DEFAULT '',
+    type        TEXT,
+    attrs       TEXT,
+    slot        INTEGER NOT NULL DEFAULT 0,
+    acquired_at INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS items_player_id ON items (player_id);
+
+-- Stacked catches are split into an item each by 0006
+INSERT INTO items (item_uid, player_id, item_name, quantity, value, img, type, attrs)
+SELECT lower(hex(randomblob(8))), player_id, item_name, quantity, value, img, type,
+       CASE WHEN catches IS NULL THEN NULL ELSE '{"catches":' || catches || '}' END
+FROM inventory;
+
+DROP TABLE inventory;
@@ -0,0 +1,209 @@
+package main
+
+import (
+    "database/sql"
+    "reflect"
+    "testing"
+)
+
+// **Open Test DB**
+// An empty SQLite database in memory, on a single connection.
+func openTestDB(t *testing.T) *sql.DB {
+    db, err := openSQLDB(sqliteDialect, ":memory:")
+    if err != nil {
+        t.Fatalf("open sqlite: %v", err)
+    }
+    db.SetMaxOpenConns(1)
+    t.Cleanup(func() { db.Close() })
+    return db
+}
+
+// **Table Names**
+// The tables in the database, without SQLite's own.
+func tableNames(t *testing.T, db *sql.DB) []string {
+    rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+    if err != nil {
+        t.Fatalf("list tables: %v", err)
+    }
+    defer rows.Close()
+    var names []string
+    for rows.Next() {
+        var name string
+        if err := rows.Scan(&name); err != nil {
+            t.Fatalf("scan table name: %v", err)
+        }
+        names = append(names, name)
+    }
+    return names
+}
+
+// **Applied Versions**
+// The versions the status lists as applied.
+func appliedVersions(t *testing.T, db *sql.DB) []int {
+    statuses, err := getMigrationStatus(db, sqliteDialect)
+    if err != nil {
+        t.Fatalf("migration status: %v", err)
+    }
+    var versions []int
+    for _, status := range statuses {
+        if status.Applied {
+            versions = append(versions, status.Version)
+        }
+    }
+    return versions
+}
+
+func TestMigrationsUpAndDown(t *testing.T) {
+    migrations, err := loadMigrations(sqliteDialect)
+    if err != nil {
+        t.Fatalf("load migrations: %v", err)
+    }
+    var all []int
+    for _, m := range migrations {
+        all = append(all, m.Version)
+    }
+    allTables := []string{"accounts", "catch_records", "items", "players", "schema_migrations"}
+
+    steps := []struct {
+        name    string
+        run     func(db *sql.DB) (int, error)
+        count   int
+        applied []int
+        tables  []string
+    }{
+        {"up from empty", func(db *sql.DB) (int, error) { return migrateUp(db, sqliteDialect) }, len(all), all, allTables},
+        {"up again", func(db *sql.DB) (int, error) { return migrateUp(db, sqliteDialect) }, 0, all, allTables},
+        {"down one", func(db *sql.DB) (int, error) { return migrateDown(db, sqliteDialect, 1) }, 1, all[:len(all)-1], allTables},
+        {"down past items", func(db *sql.DB) (int, error) { return migrateDown(db, sqliteDialect, 1) }, 1, all[:len(all)-2], []string{"accounts", "catch_records", "inventory", "players", "schema_migrations"}},
+        {"down the rest", func(db *sql.DB) (int, error) { return migrateDown(db, sqliteDialect, len(all)) }, len(all) - 2, nil, []string{"schema_migrations"}},
+        {"down with nothing applied", func(db *sql.DB) (int, error) { return migrateDown(db, sqliteDialect, 1) }, 0, nil, []string{"schema_migrations"}},
+        {"up after down", func(db *sql.DB) (int, error) { return migrateUp(db, sqliteDialect) }, len(all), all, allTables},
+    }
+    db := openTestDB(t)
+    for _, step := range steps {
+        count, err := step.run(db)
+        if err != nil {
+            t.Fatalf("%s: %v", step.name, err)
+        }
+        if count != step.count {
+            t.Errorf("%s: ran %d migration(s), want %d", step.name, count, step.count)
+        }
+        if got := appliedVersions(t, db); !reflect.DeepEqual(got, step.applied) {
+            t.Errorf("%s: applied %v, want %v", step.name, got, step.applied)
+        }
+        if got := tableNames(t, db); !reflect.DeepEqual(got, step.tables) {
+            t.Errorf("%s: tables %v, want %v", step.name, got, step.tables)
+        }
+    }
+}
+
+// Databases the store created before migrations already have every table
+// and column of the baseline.
+func TestMigrateUpFromStoreSchema(t *testing.T) {
+    db := openTestDB(t)
+    _, err := db.Exec(`CREATE TABLE players (
+        player_id TEXT PRIMARY KEY, x INTEGER NOT NULL DEFAULT 0, y INTEGER NOT NULL DEFAULT 0,
+        direction TEXT NOT NULL DEFAULT '', facing_water BOOLEAN NOT NULL DEFAULT 0,
+        balance INTEGER NOT NULL DEFAULT 0, equipped_rod TEXT)`)
+    if err != nil {
+        t.Fatalf("create players: %v", err)
+    }
+    _, err = db.Exec(`CREATE TABLE inventory (
+        player_id TEXT NOT NULL, item_name TEXT NOT NULL, quantity INTEGER NOT NULL DEFAULT 0,
+        value INTEGER NOT NULL DEFAULT 0, img TEXT NOT NULL DEFAULT '', type TEXT,
+        PRIMARY KEY (player_id, item_name))`)
+    if err != nil {
+        t.Fatalf("create inventory: %v", err)
+    }
+    if _, err := db.Exec(`INSERT INTO players (player_id, balance, equipped_rod) VALUES ('old', 50, 'rod-solid')`); err != nil {
+        t.Fatalf("insert player: %v", err)
+    }
+    if _, err := migrateUp(db, sqliteDialect); err != nil {
+        t.Fatalf("migrate up: %v", err)
+    }
+
+    s := &sqlStore{db: db, dialect: sqliteDialect}
+    player, err := s.LoadPlayer("old")
+    if err != nil {
+        t.Fatalf("load player: %v", err)
+    }
+    if player.Balance != 50 || player.EquippedRod != "rod-solid" {
+        t.Errorf("loaded balance %d and rod %q, want 50 and rod-solid", player.Balance, player.EquippedRod)
+    }
+}
+
+// Fish stacks from before item instances come out of the migrations as an
+// item per catch, so loading the player writes nothing and keeps their UIDs.
+func TestMigrateSplitsLegacyCatches(t *testing.T) {
+    db := openTestDB(t)
+    if _, err := migrateUp(db, sqliteDialect); err != nil {
+        t.Fatalf("migrate up: %v", err)
+    }
+    if _, err := migrateDown(db, sqliteDialect, 2); err != nil {
+        t.Fatalf("migrate down to the inventory table: %v", err)
+    }
+    _, err := db.Exec(`INSERT INTO players (player_id, balance) VALUES ('old', 0)`)
+    if err != nil {
+        t.Fatalf("insert player: %v", err)
+    }
+    _, err = db.Exec(`INSERT INTO inventory (player_id, item_name, quantity, value, type, catches) VALUES
+        ('old', 'Commonfish', 3, 10, 'Fish', '[{"length":20,"weight":0.5,"quality":"gold","caughtAt":1000},{"length":22,"weight":0.6,"quality":"normal","caughtAt":2000}]'),
+        ('old', 'Redfish', 2, 30, 'Fish', NULL)`)
+    if err != nil {
+        t.Fatalf("insert inventory: %v", err)
+    }
+    if _, err := migrateUp(db, sqliteDialect); err != nil {
+        t.Fatalf("migrate up: %v", err)
+    }
+
+    s := &sqlStore{db: db, dialect: sqliteDialect}
+    player, err := s.LoadPlayer("old")
+    if err != nil {
+        t.Fatalf("load player: %v", err)
+    }
+    var stacked, gold, normal, redfish int
+    for _, item := range player.Inventory {
+        switch {
+        case item.Name == "Redfish":
+            redfish += item.Quantity
+        case item.Name == "Commonfish" && item.Catch == nil:
+            stacked += item.Quantity
+        case item.Name == "Commonfish" && item.Catch.Quality == "gold" && item.Quantity == 1:
+            gold++
+        case item.Name == "Commonfish" && item.Catch.Quality == "normal" && item.Quantity == 1:
+            normal++
+        }
+    }
+    if stacked != 1 || gold != 1 || normal != 1 {
+        t.Errorf("loaded %d stacked, %d gold and %d normal Commonfish, want one of each: %+v", stacked, gold, normal, player.Inventory)
+    }
+    if redfish != 2 {
+        t.Errorf("loaded %d Redfish, want 2", redfish)
+    }
+
+    again, err := s.LoadPlayer("old")
+    if err != nil {
+        t.Fatalf("load player again: %v", err)
+    }
+    if !reflect.DeepEqual(again.Inventory, player.Inventory) {
+        t.Errorf("second load gave %+v, want the same items as the first %+v", again.Inventory, player.Inventory)
+    }
+}
+
+func TestSplitStatements(t *testing.T) {
+    tests := []struct {
+        script string
+        want   []string
+    }{
+        {"", nil},
+        {"-- only a comment\n", nil},
+        {"CREATE TABLE a (id INT);", []string{"CREATE TABLE a (id INT)"}},
+        {"-- comment\nDROP TABLE a;\n\nDROP TABLE b;\n", []string{"DROP TABLE a", "DROP TABLE b"}},
+        {"ALTER TABLE a\n    ADD COLUMN b INT", []string{"ALTER TABLE a\n    ADD COLUMN b INT"}},
+    }
+    for _, tt := range tests {
+        if got := splitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("splitStatements(%q) = %q, want %q", tt.script, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "time"
+)
+
+// **Protocol Versions**
+// The range of protocol versions the server speaks. A client announces the
+// newest version it knows in `join` and both sides use the lower of the two.
+const (
+    ProtocolVersion    = 2
+    minProtocolVersion = 1
+)
+
+// **Tile Grid Version**
+// First protocol version that gets the map in `gameState` as a compact `TileGrid`.
+const tileGridVersion = 2
+
+// **Error Codes**
+// The `code` of an `error` reply.
+const (
+    ErrCodeBadRequest         = "badRequest"         // Malformed message or invalid fields
+    ErrCodeUnknownType        = "unknownType"        // No such message type
+    ErrCodeUnsupportedVersion = "unsupportedVersion" // No protocol version in common
+    ErrCodeUnauthorized       = "unauthorized"       // Missing, invalid or mismatched session token
+    ErrCodeInvalidMove        = "invalidMove"        // Destination is blocked or off the map
+    ErrCodeCantFishHere       = "cantFishHere"       // Not facing water
+    ErrCodeNotBiting          = "notBiting"          // Catch attempt without a fish on the line
+    ErrCodeInvalidItem        = "invalidItem"        // Unknown item or bad quantity
+    ErrCodeNotForSale         = "notForSale"         // Item isn't stocked by the shop
+    ErrCodeNotOwned           = "notOwned"           // Player doesn't have the item
+    ErrCodeAlreadyOwned       = "alreadyOwned"       // Player already owns the tool
+    ErrCodeLocked             = "locked"             // Unlock requirements not met
+    ErrCodeInsufficientFunds  = "insufficientFunds"  // Balance too low
+    ErrCodeTradeFailed        = "tradeFailed"        // Trade couldn't be saved
+    ErrCodeRateLimited        = "rateLimited"        // Too many messages of this type, the request was dropped
+    ErrCodeAlreadyFishing     = "alreadyFishing"     // Cast while the line is already out
+    ErrCodeTooFast            = "tooFast"            // Reacted to a bite faster than a person can
+    ErrCodeNotReeling         = "notReeling"         // Reel input without a hooked fish
+    ErrCodeInventoryFull      = "inventoryFull"      // No free inventory slot for the item
+    ErrCodeRodBroken          = "rodBroken"          // Cast with a rod that has no durability left
+    ErrCodeNotDamaged         = "notDamaged"         // Repair of a rod at full durability
+    ErrCodeMaxLevel           = "maxLevel"           // Upgrade of a stat already at its highest level
+    ErrCodeMissingMaterials   = "missingMaterials"   // Not enough materials for the upgrade
+)
+
+// **Envelope Structure**
+// Every message a client sends, with the payload still in the codec's encoding.
+type Envelope struct {
+    Type      string // Message type, e.g. "move"
+    RequestID string // Client chosen ID echoed in replies
+    Data      []byte // Type specific payload, nil if left out
+}
+
+// **Request Structure**
+// A decoded client message, queued for the game loop.
+type Request struct {
+    Type      string
+    RequestID string
+    Player    *Player
+    Client    *Client     // Connection it came in on, stale once the player's session moves to another
+    Payload   interface{} // One of the *...Request types below
+    Received  time.Time   // When the message came off the connection
+}
+
+// **Join Request**
+// First message on a connection.
+type JoinRequest struct {
+    Version     int      `json:"version"`               // Newest protocol version the client speaks
+    PlayerID    string   `json:"playerId"`              // Account to join as
+    Token       string   `json:"token,omitempty"`       // Session token from `/login`
+    ResumeToken string   `json:"resumeToken,omitempty"` // Resume token from the last `session` message
+    Codecs      []string `json:"codecs,omitempty"`      // Codecs the client speaks, preferred first
+}
+
+// **Move Request**
+type MoveRequest struct {
+    Direction string `json:"direction"` // "up", "down", "left" or "right"
+}
+
+// **Fish Request**
+// Casts the line at the tile the player is facing.
+type FishRequest struct{}
+
+// **Catch Attempt Request**
+// Reels in after a bite.
+type CatchAttemptRequest struct{}
+
+// **Reel Request**
+// Holds or lets go of the reel while a hooked fish is fought. Sent when the
+// input changes, the server keeps the last state until the next one.
+type ReelRequest struct {
+    Reeling bool `json:"reeling"`
+}
+
+// **Sell Item Request**
+// Sells a specific item by UID, or any items with the catalog ID.
+type SellItemRequest struct {
+    ItemID   string `json:"itemId,omitempty"` // Catalog ID of the items to sell
+    UID      string `json:"uid,omitempty"`    // Instance ID of the item to sell, instead of `itemId`
+    Quantity int    `json:"quantity"`         // How many to sell
+}
+
+// **Buy Item Request**
+type BuyItemRequest struct {
+    ItemID   string `json:"itemId"`             // Catalog ID of the item to buy
+    Quantity int    `json:"quantity,omitempty"` // How many to buy, defaults to 1
+}
+
+// **Equip Rod Request**
+// Puts an owned rod in the player's hand, or with no `rodId` goes back to bare hands.
+type EquipRodRequest struct {
+    RodID string `json:"rodId"` // Catalog ID of an owned rod, empty to unequip
+}
+
+// **Sort Inventory Request**
+// Reorders the inventory, see `inventorySorts`.
+type SortInventoryRequest struct {
+    By string `json:"by"` // "name", "type", "value" or "newest"
+}
+
+// **Repair Rod Request**
+// Restores a rod's durability for the price set by the server, see `repairPrice`.
+type RepairRodRequest struct {
+    UID string `json:"uid"` // Instance ID of the rod
+}
+
+// **Upgrade Rod Request**
+// Raises a stat of a rod by a level, see `rodUpgrades`.
+type UpgradeRodRequest struct {
+    UID  string `json:"uid"`  // Instance ID of the rod
+    Stat string `json:"stat"` // "reelSpeed" or "luck"
+}
+
+// **Shop Catalog Request**
+// Asks for the items the player can see in the shop.
+type ShopCatalogRequest struct{}
+
+// **Request Validator**
+// Implemented by requests with fields that need checking beyond decoding.
+type requestValidator interface {
+    validate() error
+}
+
+func (r *JoinRequest) validate() error {
+    if r.Version < 1 {
+        return errors.New("version is required")
+    }
+    if r.PlayerID == "" {
+        return errors.New("playerId is required")
+    }
+    return nil
+}
+
+func (r *MoveRequest) validate() error {
+    switch r.Direction {
+    case "up", "down", "left", "right":
+        return nil
+    }
+    return fmt.Errorf("unknown direction %q", r.Direction)
+}
+
+func (r *SellItemRequest) validate() error {
+    if r.ItemID == "" && r.UID == "" {
+        return errors.New("itemId or uid is required")
+    }
+    if r.Quantity < 1 {
+        return errors.New("quantity must be a positive whole number")
+    }
+    return nil
+}
+
+func (r *BuyItemRequest) validate() error {
+    if r.ItemID == "" {
+        return errors.New("itemId is required")
+    }
+    if r.Quantity == 0 {
+        r.Quantity = 1
+    }
+    if r.Quantity < 1 {
+        return errors.New("quantity must be a positive whole number")
+    }
+    return nil
+}
+
+func (r *SortInventoryRequest) validate() error {
+    if _, ok := inventorySorts[r.By]; !ok {
+        return fmt.Errorf("unknown sort order %q", r.By)
+    }
+    return nil
+}
+
+func (r *RepairRodRequest) validate() error {
+    if r.UID == "" {
+        return errors.New("uid is required")
+    }
+    return nil
+}
+
+func (r *UpgradeRodRequest) validate() error {
+    if r.UID == "" {
+        return errors.New("uid is required")
+    }
+    if _, ok := rodUpgrades[r.Stat]; !ok {
+        return fmt.Errorf("unknown rod stat %q", r.Stat)
+    }
+    return nil
+}
+
+// **Request Types**
+// Constructors for the payload of every message type accepted after `join`.
+var requestTypes = map[string]func() interface{}{
+    "move":          func() interface{} { return &MoveRequest{} },
+    "fish":          func() interface{} { return &FishRequest{} },
+    "catchAttempt":  func() interface{} { return &CatchAttemptRequest{} },
+    "reel":          func() interface{} { return &ReelRequest{} },
+    "sellItem":      func() interface{} { return &SellItemRequest{} },
+    "buyItem":       func() interface{} { return &BuyItemRequest{} },
+    "equipRod":      func() interface{} { return &EquipRodRequest{} },
+    "shopCatalog":   func() interface{} { return &ShopCatalogRequest{} },
+    "sortInventory": func() interface{} { return &SortInventoryRequest{} },
+    "repairRod":     func() interface{} { return &RepairRodRequest{} },
+    "upgradeRod":    func() interface{} { return &UpgradeRodRequest{} },
+}
+
+// **Protocol Error Structure**
+// A message that couldn't be decoded, with the code to reply with.
+type ProtocolError struct {
+    Code      string
+    RequestID string
+    Message   string
+}
+
+func (e *ProtocolError) Error() string {
+    return e.Code + ": " + e.Message
+}
+
+// **Decode Envelope**
+// Decodes the outer message and its payload into `payload`.
+func decodeEnvelope(codec Codec, raw []byte, payload interface{}) (Envelope, error) {
+    env, err := codec.DecodeEnvelope(raw)
+    if err != nil {
+        return env, &ProtocolError{Code: ErrCodeBadRequest, Message: fmt.Sprintf("invalid message: %v", err)}
+    }
+    return env, decodePayload(codec, env, payload)
+}
+
+// **Decode Payload**
+// Decodes and validates the payload of an envelope. Payloads without
+// fields may leave out `data`.
+func decodePayload(codec Codec, env Envelope, payload interface{}) error {
+    if env.Data != nil {
+        if err := codec.DecodeStrict(env.Data, payload); err != nil {
+            return &ProtocolError{Code: ErrCodeBadRequest, RequestID: env.RequestID, Message: fmt.Sprintf("invalid %s data: %v", env.Type, err)}
+        }
+    }
+    if v, ok := payload.(requestValidator); ok {
+        if err := v.validate(); err != nil {
+            return &ProtocolError{Code: ErrCodeBadRequest, RequestID: env.RequestID, Message: err.Error()}
+        }
+    }
+    return nil
+}
+
+// **Decode Join**
+// Decodes the first message on a connection, which must be a `join`.
+func decodeJoin(codec Codec, raw []byte) (Envelope, *JoinRequest, error) {
+    var join JoinRequest
+    env, err := decodeEnvelope(codec, raw, &join)
+    if err == nil && env.Type != "join" {
+        err = &ProtocolError{Code: ErrCodeBadRequest, RequestID: env.RequestID, Message: "first message must be join"}
+    }
+    return env, &join, err
+}
+
+// **Decode Request**
+// Decodes a message sent after `join` into its typed request.
+func decodeRequest(codec Codec, raw []byte, player *Player) (Request, error) {
+    env, err := codec.DecodeEnvelope(raw)
+    if err != nil {
+        return Request{}, &ProtocolError{Code: ErrCodeBadRequest, Message: fmt.Sprintf("invalid message: %v", err)}
+    }
+    newPayload, ok := requestTypes[env.Type]
+    if !ok {
+        return Request{}, &ProtocolError{Code: ErrCodeUnknownType, RequestID: env.RequestID, Message: fmt.Sprintf("unknown message type %q", env.Type)}
+    }
+    payload := newPayload()
+    if err := decodePayload(codec, env, payload); err != nil {
+        return Request{}, err
+    }
+    return Request{Type: env.Type, RequestID: env.RequestID, Player: player, Payload: payload}, nil
+}
+
+// **Negotiate Version**
+// Picks the protocol version for a client that speaks up to `clientVersion`.
+func negotiateVersion(clientVersion int) (int, error) {
+    version := clientVersion
+    if version > ProtocolVersion {
+        version = ProtocolVersion
+    }
+    if version < minProtocolVersion {
+        return 0, &ProtocolError{
+            Code:    ErrCodeUnsupportedVersion,
+            Message: fmt.Sprintf("protocol version %d is not supported, server speaks %d to %d", clientVersion, minProtocolVersion, ProtocolVersion),
+        }
+    }
+    return version, nil
+}
+
+// **Error Reply**
+// The `data` of an `error` message.
+type ErrorReply struct {
+    Code      string `json:"code"`                // Machine readable reason, see the ErrCode constants
+    RequestID string `json:"requestId,omitempty"` // ID of the request that failed, if it had one
+    Message   string `json:"message"`             // Human readable description
+}
+
+// **Send Error**
+// Replies to a failed request with a uniform `error` message.
+func sendError(player *Player, requestID, code, message string) {
+    errMsg := Message{
+        Type:      "error",
+        Data:      ErrorReply{Code: code, RequestID: requestID, Message: message},
+        RequestID: requestID,
+    }
+    if err := player.Send(errMsg); err != nil {
+        ErrorLogger.Printf("Error sending %s error to player %s: %v", code, player.ID, err)
+    }
+}
+
+// **Send Ack**
+// Confirms a request that has no other reply, if the client gave it an ID.
+func sendAck(player *Player, requestID string) {
+    if requestID == "" {
+        return
+    }
+    if err := player.Send(Message{Type: "ack", RequestID: requestID}); err != nil {
+        ErrorLogger.Printf("Error sending ack to player %s: %v", player.ID, err)
+    }
+}
+
+// **Session Data**
+// The `data` of a `session` message, sent after every successful `join`.
+type SessionData struct {
+    ProtocolVersion int    `json:"protocolVersion"` // Version negotiated for this connection
+    Codec           string `json:"codec"`           // Codec negotiated for this connection
+    ResumeToken     string `json:"resumeToken"`     // Send with the next `join` to resume
+    ResumeGraceMs   int64  `json:"resumeGraceMs"`   // How long the session is held after a disconnect
+    Resumed         bool   `json:"resumed"`         // Missed messages were replayed instead of a `gameState`
+}
+
+// **Notice Data**
+// The `data` of `sessionReplaced`.
+type NoticeData struct {
+    Message string `json:"message"`
+}
+
+// **Game State Data**
+// The `data` of `gameState`.
+type GameStateData struct {
+    GameMap           [][]Tile      `json:"gameMap,omitempty"` // Protocol version 1 only
+    Tiles             *TileGrid     `json:"tiles,omitempty"`   // From `tileGridVersion` on
+    Players           []PlayerState `json:"players"`           // Public state only
+    Inventory         []Item        `json:"inventory"`
+    Balance           int           `json:"balance"`           // The joining player's own, other balances are private
+    InventoryCapacity int           `json:"inventoryCapacity"` // Slots in the inventory, see `InventoryConfig`
+}
+
+// **Tile Grid Structure**
+// The map as one byte per tile, row by row. Coordinates follow from the
+// position and visibility is tracked by the client, so only the type is sent.
+type TileGrid struct {
+    Width  int    `json:"width"`
+    Height int    `json:"height"`
+    Types  []byte `json:"types"` // Tile types, base64 in JSON and binary in MessagePack
+}
+
+// **Compact Tile Grid**
+// Packs the game map into a `TileGrid`.
+func compactTileGrid(gameMap [][]Tile) *TileGrid {
+    grid := &TileGrid{Height: len(gameMap)}
+    if grid.Height > 0 {
+        grid.Width = len(gameMap[0])
+    }
+    grid.Types = make([]byte, 0, grid.Width*grid.Height)
+    for _, row := range gameMap {
+        for _, tile := range row {
+            grid.Types = append(grid.Types, byte(tile.Type))
+        }
+    }
+    return grid
+}
+
+// **Player Left Data**
+// The `data` of `playerLeft`.
+type PlayerLeftData struct {
+    PlayerID string `json:"playerId"`
+}
+
+// **Snapshot Data**
+// The `data` of `snapshot`.
+type SnapshotData struct {
+    Tick    uint64        `json:"tick"`
+    Entered []PlayerState `json:"entered,omitempty"` // Players that came into view, in full
+    Players []PlayerDelta `json:"players"`           // Changed fields of players already in view
+    Left    []string      `json:"left,omitempty"`    // IDs of players that went out of view
+    Full    bool          `json:"full,omitempty"`    // `entered` is everyone in view, drop anyone else
+}
+
+// **Fishing Event Data**
+// The `data` of `fishingEvent`.
+type FishingEventData struct {
+    Event         string      `json:"event"`                   // "start" (a bite), "hooked", "reel", "catch" or "fail"
+    PlayerID      string      `json:"playerId"`
+    CatchWindowMs int64       `json:"catchWindowMs,omitempty"` // Set for "start"
+    Reel          *ReelParams `json:"reel,omitempty"`          // Set for "hooked"
+    Line          *LineStatus `json:"line,omitempty"`          // Set for "reel", sent every tick of the fight
+    Fish          *Fish       `json:"fish,omitempty"`          // Set for "catch"
+    Catch         *Catch      `json:"catch,omitempty"`         // Set for "catch", the fish's rolled size and quality
+    Price         int         `json:"price,omitempty"`         // Set for "catch", what the fish sells for
+    Record        bool        `json:"record,omitempty"`        // Set for "catch" when it's the player's heaviest of the species
+    Reason        string      `json:"reason,omitempty"`        // Set for "fail", one of the FishingFail... reasons
+}
+
+// **Reel Params**
+// The rules of the fight with a hooked fish.
+type ReelParams struct {
+    BandLow     float64 `json:"bandLow"`     // Lowest tension that brings the fish in
+    BandHigh    float64 `json:"bandHigh"`    // Highest tension that brings the fish in
+    TimeLimitMs int64   `json:"timeLimitMs"` // Time until the fish gets away
+}
+
+// **Line Status**
+// How the fight with a hooked fish is going.
+type LineStatus struct {
+    Tension     float64 `json:"tension"`     // 0 is slack, the line snaps at 1
+    Progress    float64 `json:"progress"`    // 0 to 1, the fish is landed at 1
+    Reeling     bool    `json:"reeling"`     // Whether the server has the reel held
+    RemainingMs int64   `json:"remainingMs"` // Time until the fish gets away
+}
+
+// **Sell Event Data**
+// The `data` of `sellEvent`.
+type SellEventData struct {
+    Event    string `json:"event"` // "itemSold"
+    PlayerID string `json:"playerId"`
+    Items    []Item `json:"items"` // What was sold, one entry per inventory item it came from
+    Earned   int    `json:"earned"`
+}
+
+// **Buy Event Data**
+// The `data` of `buyEvent`.
+type BuyEventData struct {
+    Event    string `json:"event"` // "itemBought"
+    PlayerID string `json:"playerId"`
+    Item     Item   `json:"item"`
+    Price    int    `json:"price"`
+}
+
+// **Rod Event Data**
+// The `data` of `rodEvent`.
+type RodEventData struct {
+    Event    string `json:"event"`           // "rodRepaired", "rodUpgraded" or "rodBroken"
+    PlayerID string `json:"playerId"`
+    Item     Item   `json:"item"`            // The rod afterwards
+    Price    int    `json:"price,omitempty"` // What the repair or upgrade cost
+    Stat     string `json:"stat,omitempty"`  // The upgraded stat
+}
+
+// **Shop Catalog Data**
+// The `data` of `shopCatalog`.
+type ShopCatalogData struct {
+    Items []ShopListing `json:"items"`
+}
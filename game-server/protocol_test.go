@@ -0,0 +1,102 @@
+package main
+
+import (
+    "reflect"
+    "testing"
+)
+
+// The reel message exactly as game/js/mechanics/FishingMechanic.js sends it.
+func TestDecodeRequestClientReel(t *testing.T) {
+    for _, codec := range []Codec{jsonCodec, msgpackCodec} {
+        for _, reeling := range []bool{true, false} {
+            raw, err := codec.Marshal(map[string]interface{}{
+                "type":      "reel",
+                "requestId": "abc-1",
+                "data":      map[string]interface{}{"reeling": reeling},
+            })
+            if err != nil {
+                t.Fatalf("%s: marshal: %v", codec.Name(), err)
+            }
+            req, err := decodeRequest(codec, raw, nil)
+            if err != nil {
+                t.Fatalf("%s: decode reeling=%v: %v", codec.Name(), reeling, err)
+            }
+            reel, ok := req.Payload.(*ReelRequest)
+            if !ok {
+                t.Fatalf("%s: payload is %T, want *ReelRequest", codec.Name(), req.Payload)
+            }
+            if reel.Reeling != reeling || req.RequestID != "abc-1" {
+                t.Errorf("%s: got reeling=%v id=%q, want reeling=%v id=%q", codec.Name(), reel.Reeling, req.RequestID, reeling, "abc-1")
+            }
+        }
+    }
+}
+
+// The old client shape put `reeling` next to `type`, which the strict
+// envelope rejects.
+func TestDecodeRequestReelOutsideData(t *testing.T) {
+    for _, codec := range []Codec{jsonCodec, msgpackCodec} {
+        raw, err := codec.Marshal(map[string]interface{}{"type": "reel", "reeling": true})
+        if err != nil {
+            t.Fatalf("%s: marshal: %v", codec.Name(), err)
+        }
+        _, err = decodeRequest(codec, raw, nil)
+        protoErr, ok := err.(*ProtocolError)
+        if !ok || protoErr.Code != ErrCodeBadRequest {
+            t.Errorf("%s: got %v, want a %s error", codec.Name(), err, ErrCodeBadRequest)
+        }
+    }
+}
+
+func TestDecodeRequest(t *testing.T) {
+    tests := []struct {
+        name    string
+        msg     interface{}
+        want    interface{} // Decoded payload, nil when decoding fails
+        errCode string
+    }{
+        {"move", map[string]interface{}{"type": "move", "data": map[string]interface{}{"direction": "left"}}, &MoveRequest{Direction: "left"}, ""},
+        {"move nowhere", map[string]interface{}{"type": "move", "data": map[string]interface{}{"direction": "north"}}, nil, ErrCodeBadRequest},
+        {"sell by id", map[string]interface{}{"type": "sellItem", "data": map[string]interface{}{"itemId": "redfish", "quantity": 2}}, &SellItemRequest{ItemID: "redfish", Quantity: 2}, ""},
+        {"sell by uid", map[string]interface{}{"type": "sellItem", "data": map[string]interface{}{"uid": "abc", "quantity": 1}}, &SellItemRequest{UID: "abc", Quantity: 1}, ""},
+        {"sell nothing", map[string]interface{}{"type": "sellItem", "data": map[string]interface{}{"quantity": 1}}, nil, ErrCodeBadRequest},
+        {"sell none", map[string]interface{}{"type": "sellItem", "data": map[string]interface{}{"itemId": "redfish", "quantity": 0}}, nil, ErrCodeBadRequest},
+        {"buy one by default", map[string]interface{}{"type": "buyItem", "data": map[string]interface{}{"itemId": "rod-solid"}}, &BuyItemRequest{ItemID: "rod-solid", Quantity: 1}, ""},
+        {"buy negative", map[string]interface{}{"type": "buyItem", "data": map[string]interface{}{"itemId": "redfish", "quantity": -1}}, nil, ErrCodeBadRequest},
+        {"equip", map[string]interface{}{"type": "equipRod", "data": map[string]interface{}{"rodId": "rod-solid"}}, &EquipRodRequest{RodID: "rod-solid"}, ""},
+        {"unequip", map[string]interface{}{"type": "equipRod", "data": map[string]interface{}{"rodId": ""}}, &EquipRodRequest{}, ""},
+        {"shop without data", map[string]interface{}{"type": "shopCatalog"}, &ShopCatalogRequest{}, ""},
+        {"sort", map[string]interface{}{"type": "sortInventory", "data": map[string]interface{}{"by": "newest"}}, &SortInventoryRequest{By: "newest"}, ""},
+        {"sort by colour", map[string]interface{}{"type": "sortInventory", "data": map[string]interface{}{"by": "colour"}}, nil, ErrCodeBadRequest},
+        {"repair without uid", map[string]interface{}{"type": "repairRod", "data": map[string]interface{}{}}, nil, ErrCodeBadRequest},
+        {"upgrade", map[string]interface{}{"type": "upgradeRod", "data": map[string]interface{}{"uid": "abc", "stat": "luck"}}, &UpgradeRodRequest{UID: "abc", Stat: "luck"}, ""},
+        {"upgrade unknown stat", map[string]interface{}{"type": "upgradeRod", "data": map[string]interface{}{"uid": "abc", "stat": "speed"}}, nil, ErrCodeBadRequest},
+        {"unknown field", map[string]interface{}{"type": "move", "data": map[string]interface{}{"direction": "up", "run": true}}, nil, ErrCodeBadRequest},
+        {"unknown type", map[string]interface{}{"type": "teleport"}, nil, ErrCodeUnknownType},
+        {"join after join", map[string]interface{}{"type": "join", "data": map[string]interface{}{"version": 1, "playerId": "p1"}}, nil, ErrCodeUnknownType},
+        {"not an object", "move", nil, ErrCodeBadRequest},
+    }
+    for _, codec := range []Codec{jsonCodec, msgpackCodec} {
+        for _, tt := range tests {
+            raw, err := codec.Marshal(tt.msg)
+            if err != nil {
+                t.Fatalf("%s/%s: marshal: %v", codec.Name(), tt.name, err)
+            }
+            req, err := decodeRequest(codec, raw, nil)
+            if tt.errCode != "" {
+                protoErr, ok := err.(*ProtocolError)
+                if !ok || protoErr.Code != tt.errCode {
+                    t.Errorf("%s/%s: got %v, want a %s error", codec.Name(), tt.name, err, tt.errCode)
+                }
+                continue
+            }
+            if err != nil {
+                t.Errorf("%s/%s: decode: %v", codec.Name(), tt.name, err)
+                continue
+            }
+            if !reflect.DeepEqual(req.Payload, tt.want) {
+                t.Errorf("%s/%s: payload %+v, want %+v", codec.Name(), tt.name, req.Payload, tt.want)
+            }
+        }
+    }
+}
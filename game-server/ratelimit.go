@@ -0,0 +1,177 @@
+package main
+
+import (
+    "net"
+    "net/http"
+    "sync"
+    "time"
+)
+
+// **Token Bucket**
+// Budget for one message type. Refills at the limit's rate up to its burst.
+type tokenBucket struct {
+    tokens float64
+    last   time.Time
+}
+
+// **Rate Limiter**
+// Per-player budgets by message type, plus the rejections counted against
+// the player. Kept in the session, so reconnecting doesn't refill it.
+type rateLimiter struct {
+    mu      sync.Mutex
+    buckets map[string]*tokenBucket
+    strikes []time.Time // Rejected messages within the strike window, oldest first
+}
+
+// **Rate Offenses**
+// How often each player was disconnected for flooding since the server
+// started, so repeat offenders stand out in the logs. Guarded by `mu`.
+var rateOffenses = make(map[string]int)
+
+// **New Rate Limiter**
+func newRateLimiter() *rateLimiter {
+    return &rateLimiter{buckets: make(map[string]*tokenBucket)}
+}
+
+// **Limit For**
+// The budget for a message type, falling back to the "default" one.
+func limitFor(msgType string) RateLimit {
+    if limit, ok := config.RateLimit.Messages[msgType]; ok {
+        return limit
+    }
+    return config.RateLimit.Messages["default"]
+}
+
+// **Allow**
+// Takes a token from the message type's bucket, reporting false if it's empty.
+func (l *rateLimiter) allow(msgType string, limit RateLimit, now time.Time) bool {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    b, ok := l.buckets[msgType]
+    if !ok {
+        b = &tokenBucket{tokens: float64(limit.Burst), last: now}
+        l.buckets[msgType] = b
+    }
+    b.tokens += now.Sub(b.last).Seconds() * limit.Rate
+    if b.tokens > float64(limit.Burst) {
+        b.tokens = float64(limit.Burst)
+    }
+    b.last = now
+    if b.tokens < 1 {
+        return false
+    }
+    b.tokens--
+    return true
+}
+
+// **Strike**
+// Counts a rejected message and returns how many there were within the strike window.
+func (l *rateLimiter) strike(now time.Time) int {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    cutoff := now.Add(-config.RateLimit.StrikeWindow.Duration)
+    kept := l.strikes[:0]
+    for _, t := range l.strikes {
+        if t.After(cutoff) {
+            kept = append(kept, t)
+        }
+    }
+    l.strikes = append(kept, now)
+    return len(l.strikes)
+}
+
+// **Session Limiter**
+// The rate limiter of the player's session.
+func sessionLimiter(playerID string) *rateLimiter {
+    mu.Lock()
+    defer mu.Unlock()
+    sess, ok := sessions[playerID]
+    if !ok {
+        // The session is gone already, this connection is about to end
+        return newRateLimiter()
+    }
+    if sess.limiter == nil {
+        sess.limiter = newRateLimiter()
+    }
+    return sess.limiter
+}
+
+// **Throttle**
+// Checks a request against the player's budget. Over budget, the request is
+// answered with a `rateLimited` error, and a client that keeps flooding after
+// `MaxStrikes` warnings is flagged and disconnected. Reports whether the
+// request may go ahead.
+func throttle(player *Player, client *Client, limiter *rateLimiter, msgType, requestID string) bool {
+    now := time.Now()
+    if limiter.allow(msgType, limitFor(msgType), now) {
+        return true
+    }
+
+    strikes := limiter.strike(now)
+    if strikes <= config.RateLimit.MaxStrikes {
+        DebugLogger.Printf("Player %s is over the %q rate limit (%d strikes)", player.ID, msgType, strikes)
+        sendError(player, requestID, ErrCodeRateLimited, "Too many requests, slow down")
+        return false
+    }
+    if strikes == config.RateLimit.MaxStrikes+1 {
+        mu.Lock()
+        rateOffenses[player.ID]++
+        offenses := rateOffenses[player.ID]
+        mu.Unlock()
+        WarningLogger.Printf("Flagged player %s for flooding %q messages, disconnecting (offense %d)", player.ID, msgType, offenses)
+        client.Kick(Message{Type: "kicked", Data: NoticeData{Message: "Disconnected for sending too many requests"}}, "rate limited")
+    }
+    return false
+}
+
+// **Auth Limiters**
+// Login and registration budgets by client IP, so passwords can't be guessed
+// as fast as the server can hash them. Idle IPs are dropped once the map grows.
+var (
+    authLimitersMu sync.Mutex
+    authLimiters   = make(map[string]*rateLimiter)
+)
+
+// **Auth Limiters Prune Size**
+// Entries kept before idle ones are looked for.
+const authLimitersPruneSize = 1024
+
+// **Allow Auth Attempt**
+// Takes a login or registration attempt from the client IP's budget. The IP
+// is the connecting address; proxy headers aren't trusted.
+func allowAuthAttempt(r *http.Request) bool {
+    ip, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        ip = r.RemoteAddr
+    }
+    limit := config.RateLimit.Auth
+    now := time.Now()
+
+    authLimitersMu.Lock()
+    limiter, ok := authLimiters[ip]
+    if !ok {
+        if len(authLimiters) >= authLimitersPruneSize {
+            pruneAuthLimitersLocked(limit, now)
+        }
+        limiter = newRateLimiter()
+        authLimiters[ip] = limiter
+    }
+    authLimitersMu.Unlock()
+    return limiter.allow("auth", limit, now)
+}
+
+// **Prune Auth Limiters (Locked)**
+// Drops the IPs whose budget has refilled, they'd start out the same anyway.
+// Caller must hold `authLimitersMu`.
+func pruneAuthLimitersLocked(limit RateLimit, now time.Time) {
+    refill := time.Duration(float64(limit.Burst) / limit.Rate * float64(time.Second))
+    for ip, limiter := range authLimiters {
+        limiter.mu.Lock()
+        b, ok := limiter.buckets["auth"]
+        idle := !ok || now.Sub(b.last) >= refill
+        limiter.mu.Unlock()
+        if idle {
+            delete(authLimiters, ip)
+        }
+    }
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+    "math"
+    "math/rand"
+    "time"
+)
+
+// **Reel Game**
+// The fight with a hooked fish, simulated by the game loop. Reeling raises
+// the line tension, as does the fish pulling, and letting the line go slack
+// lowers it. Only time spent with the tension inside the band brings the fish
+// in, letting it sag below the band gives the fish line back, and pulling the
+// tension up to 1 snaps the line. The client only says whether the reel is held.
+type reelGame struct {
+    fish      Fish
+    power     float64       // Rod's reel power
+    speed     float64       // Rod's reel speed, scales how fast time in the band counts
+    bandLow   float64       // Tension range that brings the fish in
+    bandHigh  float64
+    fightTime time.Duration // Time inside the band needed to land the fish
+    deadline  time.Time     // When the fish gets away
+    tension   float64       // 0 is a slack line, 1 snaps it
+    progress  float64       // 0 to 1, landed at 1
+    reeling   bool          // Whether the player holds the reel
+    phase     float64       // Where the fish's pull is in its surge cycle
+    lunge     time.Duration // Remaining time of the fish's current lunge
+}
+
+// **Reel Tuning**
+const (
+    reelSnapTension   = 1.0  // Tension that snaps the line
+    reelStartTension  = 0.3  // Tension right after the hook is set
+    reelSlackRate     = 0.6  // Tension lost per second while the reel is let go
+    reelLungeChance   = 0.5  // Lunges per second from a fish of strength 1
+    reelLungeDuration = 400 * time.Millisecond
+)
+
+// **New Reel Game**
+// Sets up the fight with `fish` on `rod`. Stronger fish narrow the rod's band,
+// and the fish gets away after twice its fight time plus `ReelGrace`.
+func newReelGame(fish Fish, rod RodStats, now time.Time) *reelGame {
+    width := rod.TensionBand * (1 - 0.5*fish.Strength)
+    return &reelGame{
+        fish:      fish,
+        power:     rod.ReelPower,
+        speed:     rod.ReelSpeed,
+        bandLow:   0.5 - width/2,
+        bandHigh:  0.5 + width/2,
+        fightTime: fish.FightTime,
+        deadline:  now.Add(2*fish.FightTime + config.Fishing.ReelGrace.Duration),
+        tension:   reelStartTension,
+    }
+}
+
+// **Pull**
+// How hard the fish pulls right now, surging and now and then lunging.
+func (g *reelGame) pull(dt time.Duration) float64 {
+    seconds := dt.Seconds()
+    g.phase += seconds * (1.5 + 2*g.fish.Strength)
+    if g.lunge > 0 {
+        g.lunge -= dt
+    } else if rand.Float64() < reelLungeChance*g.fish.Strength*seconds {
+        g.lunge = reelLungeDuration
+    }
+    pull := g.fish.Strength * (0.6 + 0.4*math.Sin(g.phase))
+    if g.lunge > 0 {
+        pull *= 2
+    }
+    return pull
+}
+
+// **Step**
+// Advances the fight by `dt`.
+func (g *reelGame) step(dt time.Duration) {
+    seconds := dt.Seconds()
+    pull := g.pull(dt)
+    if g.reeling {
+        g.tension += (g.power + pull) * 0.5 * seconds
+    } else {
+        g.tension = math.Max(0, g.tension+(pull*0.25-reelSlackRate)*seconds)
+    }
+
+    gain := seconds * g.speed / g.fightTime.Seconds()
+    switch {
+    case g.tension < g.bandLow:
+        g.progress = math.Max(0, g.progress-gain/2)
+    case g.tension <= g.bandHigh:
+        g.progress += gain
+    }
+}
+
+// **Params**
+// The fight's rules as sent with the "hooked" event.
+func (g *reelGame) params() *ReelParams {
+    return &ReelParams{
+        BandLow:     g.bandLow,
+        BandHigh:    g.bandHigh,
+        TimeLimitMs: time.Until(g.deadline).Milliseconds(),
+    }
+}
+
+// **Handle Reel**
+// Holds or lets go of the reel during a fight.
+func handleReel(req Request, reel *ReelRequest) {
+    player := req.Player
+    session, ok := fishingSessions[player.ID]
+    if !ok || session.state != fishingReeling {
+        sendError(player, req.RequestID, ErrCodeNotReeling, "Nothing is on the line")
+        return
+    }
+    session.reel.reeling = reel.Reeling
+    sendAck(player, req.RequestID)
+}
+
+// **Simulate Reeling**
+// Tick system that advances every fight and tells the anglers how their
+// line is doing. Lands the fish or snaps the line when the fight is decided,
+// the time limit is the session's timer. A disconnected angler isn't sent
+// the line's status, a status every tick would fill the detached queue and
+// only the latest matters; once resumed, the next tick sends them that.
+func simulateReeling(tick uint64, now time.Time) {
+    dt := time.Second / time.Duration(config.Server.TickRate)
+    for _, session := range fishingSessions {
+        if session.state != fishingReeling {
+            continue
+        }
+        player, game := session.player, session.reel
+        game.step(dt)
+        switch {
+        case game.tension >= reelSnapTension:
+            DebugLogger.Printf("Player %s snapped their line on a %s", player.ID, game.fish.Name)
+            resolveFishing(player, session, FishingFailLineSnapped)
+            continue
+        case game.progress >= 1:
+            reelIn(player, session)
+            continue
+        }
+
+        mu.Lock()
+        if player.Client == nil || player.Client.conn == nil {
+            mu.Unlock()
+            continue
+        }
+        player.Send(Message{
+            Type: "fishingEvent",
+            Data: FishingEventData{
+                Event:    "reel",
+                PlayerID: player.ID,
+                Line: &LineStatus{
+                    Tension:     math.Min(game.tension, reelSnapTension),
+                    Progress:    game.progress,
+                    Reeling:     game.reeling,
+                    RemainingMs: game.deadline.Sub(now).Milliseconds(),
+                },
+            },
+        })
+        mu.Unlock()
+    }
+}
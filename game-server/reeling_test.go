@@ -0,0 +1,99 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/gorilla/websocket"
+)
+
+// **Test Connection**
+// A WebSocket connection within the test, returning the server's end and the peer's.
+func testConnection(t *testing.T) (*websocket.Conn, *websocket.Conn) {
+    accepted := make(chan *websocket.Conn, 1)
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        conn, err := upgrader.Upgrade(w, r, nil)
+        if err != nil {
+            t.Errorf("upgrade: %v", err)
+            return
+        }
+        accepted <- conn
+    }))
+    t.Cleanup(srv.Close)
+    peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+    if err != nil {
+        t.Fatalf("dial: %v", err)
+    }
+    t.Cleanup(func() { peer.Close() })
+    return <-accepted, peer
+}
+
+// A fight that goes on while the angler is disconnected mustn't fill their
+// detached queue, and the line's status reaches them once they resume.
+func TestReelStatusWhileDetached(t *testing.T) {
+    sendQueueSize := config.Server.SendQueueSize
+    config.Server.SendQueueSize = 8
+    defer func() { config.Server.SendQueueSize = sendQueueSize }()
+    useStore(t, newMemoryStore())
+    playerID := savedTestPlayer(t, 100).ID
+
+    conn, _ := testConnection(t)
+    player, _, err := attachSession(playerID, newClient(conn, playerID, ProtocolVersion, jsonCodec), "")
+    if err != nil {
+        t.Fatalf("attach: %v", err)
+    }
+    session := &fishingSession{state: fishingReeling, player: player, reel: newReelGame(fishList[0], bareHands, time.Now().Add(time.Minute))}
+    fishingSessions[playerID] = session
+    defer func() {
+        delete(fishingSessions, playerID)
+        mu.Lock()
+        if sess, ok := sessions[playerID]; ok && sess.expiry != nil {
+            sess.expiry.Stop()
+        }
+        player.Client.Close()
+        delete(players, playerID)
+        delete(sessions, playerID)
+        unindexPlayerLocked(playerID)
+        mu.Unlock()
+    }()
+    // Holds the line in the band without landing the fish, however many ticks it takes
+    tick := func() {
+        session.reel.tension = (session.reel.bandLow + session.reel.bandHigh) / 2
+        session.reel.progress = 0
+        simulateReeling(0, time.Now())
+    }
+
+    // The connection drops as the read loop would see it
+    mu.Lock()
+    player.Client.Close()
+    detachSessionLocked(player)
+    resumeToken := sessions[playerID].resumeToken
+    mu.Unlock()
+    for i := 0; i < config.Server.SendQueueSize; i++ {
+        tick()
+    }
+
+    conn, peer := testConnection(t)
+    _, kind, err := attachSession(playerID, newClient(conn, playerID, ProtocolVersion, jsonCodec), resumeToken)
+    if err != nil {
+        t.Fatalf("resume: %v", err)
+    }
+    if kind != joinResumed {
+        t.Fatalf("join kind %v after the fight went on while detached, want resumed", kind)
+    }
+    tick()
+
+    peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+    for {
+        _, raw, err := peer.ReadMessage()
+        if err != nil {
+            t.Fatalf("no reel status after resuming: %v", err)
+        }
+        if strings.Contains(string(raw), `"event":"reel"`) {
+            break
+        }
+    }
+}
@@ -0,0 +1,191 @@
+package main
+
+// **Player State Structure**
+// The public part of a player, the only state other players are sent.
+// Balance and inventory stay private to the owner.
+type PlayerState struct {
+    ID          string `json:"id"`
+    X           int    `json:"x"`
+    Y           int    `json:"y"`
+    Direction   string `json:"direction"`
+    FacingWater bool   `json:"facingWater"`
+    EquippedRod string `json:"equippedRod"`
+    Idle        bool   `json:"idle"`
+    AFK         bool   `json:"afk"`
+}
+
+// **Player Delta Structure**
+// The public fields of a player that changed since a client was last sent
+// them. Missing fields are unchanged.
+type PlayerDelta struct {
+    ID          string  `json:"id"`
+    X           *int    `json:"x,omitempty"`
+    Y           *int    `json:"y,omitempty"`
+    Direction   *string `json:"direction,omitempty"`
+    FacingWater *bool   `json:"facingWater,omitempty"`
+    EquippedRod *string `json:"equippedRod,omitempty"`
+    Idle        *bool   `json:"idle,omitempty"`
+    AFK         *bool   `json:"afk,omitempty"`
+}
+
+// **Public Player State**
+// Copies the fields of a player that other players are sent.
+func publicPlayerState(player *Player) PlayerState {
+    return PlayerState{
+        ID:          player.ID,
+        X:           player.X,
+        Y:           player.Y,
+        Direction:   player.Direction,
+        FacingWater: player.FacingWater,
+        EquippedRod: player.EquippedRod,
+        Idle:        player.Idle,
+        AFK:         player.AFK,
+    }
+}
+
+// **Diff Player State**
+// The delta that takes a client from `prev` to `cur`, and whether anything changed.
+func diffPlayerState(prev, cur PlayerState) (PlayerDelta, bool) {
+    delta := PlayerDelta{ID: cur.ID}
+    changed := false
+    if prev.X != cur.X {
+        delta.X, changed = &cur.X, true
+    }
+    if prev.Y != cur.Y {
+        delta.Y, changed = &cur.Y, true
+    }
+    if prev.Direction != cur.Direction {
+        delta.Direction, changed = &cur.Direction, true
+    }
+    if prev.FacingWater != cur.FacingWater {
+        delta.FacingWater, changed = &cur.FacingWater, true
+    }
+    if prev.EquippedRod != cur.EquippedRod {
+        delta.EquippedRod, changed = &cur.EquippedRod, true
+    }
+    if prev.Idle != cur.Idle {
+        delta.Idle, changed = &cur.Idle, true
+    }
+    if prev.AFK != cur.AFK {
+        delta.AFK, changed = &cur.AFK, true
+    }
+    return delta, changed
+}
+
+// **Dirty Players**
+// IDs of players whose public state may have changed since the last snapshot. Guarded by `mu`.
+var dirtyPlayers = make(map[string]bool)
+
+// **Mark Dirty (Locked)**
+// Includes the player in the next snapshot. Caller must hold `mu`.
+func markDirtyLocked(playerID string) {
+    dirtyPlayers[playerID] = true
+}
+
+// **Visible Player States (Locked)**
+// The public state of every player the viewer can see. Caller must hold `mu`.
+func visiblePlayerStatesLocked(viewer *Player) []PlayerState {
+    visible := playersInViewLocked(viewer)
+    states := make([]PlayerState, 0, len(visible))
+    for _, p := range visible {
+        states = append(states, publicPlayerState(p))
+    }
+    return states
+}
+
+// **Send Full Snapshot (Locked)**
+// Sends the client every player in view in full and makes that its
+// replicated state. Caller must hold `mu`.
+func sendFullSnapshotLocked(viewer *Player, client *Client) error {
+    entered := visiblePlayerStatesLocked(viewer)
+    replicated := make(map[string]PlayerState, len(entered))
+    for _, state := range entered {
+        replicated[state.ID] = state
+    }
+    client.replicated = replicated
+    client.resync = false
+
+    return client.Send(Message{
+        Type: "snapshot",
+        Data: SnapshotData{
+            Tick:    currentTick,
+            Entered: entered,
+            Players: []PlayerDelta{},
+            Full:    true,
+        },
+    })
+}
+
+// **Flush Snapshot**
+// Sends each client one `snapshot` with the players that came into view, the
+// fields that changed for players already in view, and the players that went
+// out of view, all relative to what that client was last sent.
+//
+// Clients don't acknowledge snapshots: a queued message counts as delivered,
+// as the connection is reliable until it's lost. Whatever a lost connection
+// dropped, the next one is resynced rather than patched up. A new connection
+// starts from its `gameState`, a resumed one is sent a full snapshot, and a
+// client whose queue overflowed is sent one if it's still around next tick.
+func flushSnapshot() {
+    mu.Lock()
+    defer mu.Unlock()
+
+    // Who can see whom only changes when someone joins or moves, which marks them dirty
+    moved := len(dirtyPlayers) > 0
+    for id, viewer := range players {
+        client := viewer.Client
+        if client == nil {
+            continue
+        }
+        if client.resync {
+            if err := sendFullSnapshotLocked(viewer, client); err != nil {
+                ErrorLogger.Printf("Error sending full snapshot to player %s: %v", id, err)
+            }
+            continue
+        }
+        if !moved {
+            continue
+        }
+
+        snapshot := SnapshotData{Tick: currentTick, Players: []PlayerDelta{}}
+        visible := make(map[string]PlayerState)
+        for _, state := range visiblePlayerStatesLocked(viewer) {
+            visible[state.ID] = state
+            prev, seen := client.replicated[state.ID]
+            if !seen {
+                snapshot.Entered = append(snapshot.Entered, state)
+            } else if dirtyPlayers[state.ID] {
+                if delta, changed := diffPlayerState(prev, state); changed {
+                    snapshot.Players = append(snapshot.Players, delta)
+                }
+            }
+        }
+        for otherID := range client.replicated {
+            if _, ok := visible[otherID]; !ok {
+                snapshot.Left = append(snapshot.Left, otherID)
+            }
+        }
+        if len(snapshot.Entered) == 0 && len(snapshot.Players) == 0 && len(snapshot.Left) == 0 {
+            continue
+        }
+
+        if err := client.Send(Message{Type: "snapshot", Data: snapshot}); err != nil {
+            ErrorLogger.Printf("Error sending snapshot to player %s: %v", id, err)
+            client.resync = true
+            continue
+        }
+        for _, state := range snapshot.Entered {
+            client.replicated[state.ID] = state
+        }
+        for _, delta := range snapshot.Players {
+            client.replicated[delta.ID] = visible[delta.ID]
+        }
+        for _, otherID := range snapshot.Left {
+            delete(client.replicated, otherID)
+        }
+    }
+
+    for id := range dirtyPlayers {
+        delete(dirtyPlayers, id)
+    }
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+    "encoding/json"
+    "testing"
+)
+
+// A client whose baseline went stale, as when a queued snapshot was lost with
+// its connection, must be sent everyone in view in full and start over from that.
+func TestResyncReplacesStaleBaseline(t *testing.T) {
+    viewer := &Player{ID: "viewer", X: 5, Y: 5, Direction: "down"}
+    other := &Player{ID: "other", X: 6, Y: 5, Direction: "left"}
+    client := &Client{playerID: viewer.ID, codec: jsonCodec, send: make(chan []byte, 4), done: make(chan struct{})}
+    viewer.Client = client
+
+    mu.Lock()
+    for _, p := range []*Player{viewer, other} {
+        players[p.ID] = p
+        indexPlayerLocked(p)
+    }
+    // The client never got the delta that moved `other` away from 1,5, and
+    // still has someone in view who has since left
+    client.replicated = map[string]PlayerState{
+        other.ID: {ID: other.ID, X: 1, Y: 5, Direction: "left"},
+        "gone":   {ID: "gone", X: 4, Y: 4},
+    }
+    client.resync = true
+    mu.Unlock()
+    defer func() {
+        mu.Lock()
+        for _, p := range []*Player{viewer, other} {
+            delete(players, p.ID)
+            unindexPlayerLocked(p.ID)
+        }
+        mu.Unlock()
+    }()
+
+    flushSnapshot()
+
+    if len(client.send) != 1 {
+        t.Fatalf("%d messages sent, want one snapshot", len(client.send))
+    }
+    var msg struct {
+        Type string       `json:"type"`
+        Data SnapshotData `json:"data"`
+    }
+    if err := json.Unmarshal(<-client.send, &msg); err != nil {
+        t.Fatalf("decode snapshot: %v", err)
+    }
+    if msg.Type != "snapshot" || !msg.Data.Full {
+        t.Fatalf("sent a %s (full %v), want a full snapshot", msg.Type, msg.Data.Full)
+    }
+    entered := make(map[string]PlayerState)
+    for _, state := range msg.Data.Entered {
+        entered[state.ID] = state
+    }
+    if state, ok := entered[other.ID]; !ok || state.X != 6 {
+        t.Errorf("snapshot has %s at %+v, want them at x 6", other.ID, state)
+    }
+    mu.Lock()
+    defer mu.Unlock()
+    if client.resync {
+        t.Error("client still marked for a resync")
+    }
+    if _, ok := client.replicated["gone"]; ok {
+        t.Error("baseline still has a player who left")
+    }
+    if client.replicated[other.ID].X != 6 {
+        t.Errorf("baseline has %s at x %d, want 6", other.ID, client.replicated[other.ID].X)
+    }
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+    "math"
+    "math/rand"
+    "time"
+)
+
+// **Rod Stats Structure**
+// How a rod changes the fishing process.
+type RodStats struct {
+    Level       int           // Highest fish tier the rod can land
+    BiteChance  float64       // Chance (0-1) that a fish bites at all
+    MinWait     time.Duration // Shortest wait before a bite
+    MaxWait     time.Duration // Longest wait before a bite
+    CatchWindow time.Duration // Time the player has to react to a bite
+    Luck        float64       // Extra weight given to rare fish (0 = none, 1 = doubled)
+    ReelPower   float64       // How fast reeling builds up line tension
+    TensionBand float64       // Width of the tension band that reels a fish in, before its strength narrows it
+    ReelSpeed   float64       // How fast time inside the band brings the fish in (1 = normal)
+    Durability  int           // Durability of a new rod, 0 never wears out
+}
+
+// **Bare Hands**
+// Stats used when the player has no rod equipped.
+var bareHands = RodStats{
+    Level:       0,
+    BiteChance:  0.8,
+    MinWait:     1 * time.Second,
+    MaxWait:     5 * time.Second,
+    CatchWindow: 3 * time.Second,
+    Luck:        0,
+    ReelPower:   0.6,
+    TensionBand: 0.25,
+    ReelSpeed:   1,
+    Durability:  0,
+}
+
+// **Rod Stats By ID**
+// Stats for every rod in `poleList`, keyed by catalog ID.
+var rodStats = map[string]RodStats{
+    "rod-half-decent": {Level: 1, BiteChance: 0.85, MinWait: 1 * time.Second, MaxWait: 4 * time.Second, CatchWindow: 3500 * time.Millisecond, Luck: 0.25, ReelPower: 0.7, TensionBand: 0.3, ReelSpeed: 1, Durability: 100},
+    "rod-solid":       {Level: 2, BiteChance: 0.9, MinWait: 1 * time.Second, MaxWait: 4 * time.Second, CatchWindow: 4 * time.Second, Luck: 0.5, ReelPower: 0.8, TensionBand: 0.35, ReelSpeed: 1, Durability: 150},
+    "rod-fishinator":  {Level: 3, BiteChance: 0.95, MinWait: 1 * time.Second, MaxWait: 3 * time.Second, CatchWindow: 4500 * time.Millisecond, Luck: 1, ReelPower: 0.9, TensionBand: 0.4, ReelSpeed: 1, Durability: 200},
+    "rod-rocket":      {Level: 4, BiteChance: 1, MinWait: 500 * time.Millisecond, MaxWait: 2 * time.Second, CatchWindow: 5 * time.Second, Luck: 2, ReelPower: 1, TensionBand: 0.5, ReelSpeed: 1, Durability: 300},
+}
+
+// **Rare Fish Rarity**
+// Fish at or below this rarity count as rare and benefit from rod luck.
+const rareFishRarity = 10
+
+// **Rod Wear**
+const (
+    castWear = 1 // Durability every cast costs
+    landWear = 8 // Durability landing a fish of strength 1 costs, weaker fish cost less
+)
+
+// **Rod State Structure**
+// The wear and upgrades of one rod. Never changed in place once it's on an
+// item, changes replace it with a modified `clone`.
+type RodState struct {
+    Durability    int            `json:"durability"`         // Remaining durability, the rod can't be cast at 0
+    MaxDurability int            `json:"maxDurability"`      // Durability of the rod when new or repaired
+    Upgrades      map[string]int `json:"upgrades,omitempty"` // Level per upgraded stat, see `rodUpgrades`
+}
+
+// **New Rod State**
+// The state of a rod fresh from the shop.
+func newRodState(stats RodStats) *RodState {
+    return &RodState{Durability: stats.Durability, MaxDurability: stats.Durability}
+}
+
+// **Clone**
+// A copy of the state that can be changed without touching the original.
+func (r *RodState) clone() *RodState {
+    cloned := *r
+    if r.Upgrades != nil {
+        cloned.Upgrades = make(map[string]int, len(r.Upgrades))
+        for stat, level := range r.Upgrades {
+            cloned.Upgrades[stat] = level
+        }
+    }
+    return &cloned
+}
+
+// **With Max Durability**
+// A copy of the state for a rod whose catalog durability is now `max`.
+func (r *RodState) withMaxDurability(max int) *RodState {
+    cloned := r.clone()
+    cloned.MaxDurability = max
+    if cloned.Durability > max {
+        cloned.Durability = max
+    }
+    return cloned
+}
+
+// **Broken**
+// Whether the rod is worn out. Rods without state never wear.
+func (r *RodState) broken() bool {
+    return r != nil && r.MaxDurability > 0 && r.Durability <= 0
+}
+
+// **Landing Wear**
+// Durability lost landing the fish, scaled by how hard it fights.
+func landingWear(fish Fish) int {
+    return int(math.Max(1, math.Round(landWear*fish.Strength)))
+}
+
+// **Equipped Rod**
+// Returns the inventory item of the player's equipped rod, if they still own it.
+// Caller must hold `mu`.
+func equippedRod(player *Player) (Item, bool) {
+    if player.EquippedRod == "" {
+        return Item{}, false
+    }
+    for _, invItem := range player.Inventory {
+        if invItem.ID == player.EquippedRod {
+            return invItem, true
+        }
+    }
+    return Item{}, false
+}
+
+// **Equipped Rod Stats**
+// Returns the stats of the player's equipped rod with its upgrades applied, or
+// bare hands if none is equipped or the player no longer owns it. Caller must hold `mu`.
+func equippedRodStats(player *Player) RodStats {
+    rod, ok := equippedRod(player)
+    if !ok {
+        return bareHands
+    }
+    stats, ok := rodStats[rod.ID]
+    if !ok {
+        return bareHands
+    }
+    return stats.upgraded(rod.Rod)
+}
+
+// **Wear Rod (Locked)**
+// Takes `wear` durability off the rod with the UID, reporting whether that
+// broke it. The caller saves the player. Caller must hold `mu`.
+func wearRodLocked(player *Player, uid string, wear int) bool {
+    i := findItem(player.Inventory, uid)
+    if uid == "" || i < 0 || player.Inventory[i].Rod == nil || player.Inventory[i].Rod.MaxDurability == 0 {
+        return false
+    }
+    rod := player.Inventory[i].Rod.clone()
+    if rod.Durability <= 0 {
+        return false
+    }
+    rod.Durability -= wear
+    if rod.Durability < 0 {
+        rod.Durability = 0
+    }
+
+    inventory := make([]Item, len(player.Inventory))
+    copy(inventory, player.Inventory)
+    inventory[i].Rod = rod
+    player.Inventory = inventory
+    if rod.Durability == 0 {
+        DebugLogger.Printf("Player %s broke their %s", player.ID, inventory[i].Name)
+        return true
+    }
+    return false
+}
+
+// **Send Rod Broken (Locked)**
+// Tells the player the rod just broke. Caller must hold `mu`.
+func sendRodBrokenLocked(player *Player, uid string, requestID string) {
+    i := findItem(player.Inventory, uid)
+    if i < 0 {
+        return
+    }
+    player.Send(Message{
+        Type:      "rodEvent",
+        Player:    player,
+        RequestID: requestID,
+        Data: RodEventData{
+            Event:    "rodBroken",
+            PlayerID: player.ID,
+            Item:     player.Inventory[i],
+        },
+    })
+}
+
+// **Equip Rod**
+// Sets the player's equipped rod after checking they own it. An empty `rodID`
+// unequips the rod so the player fishes bare handed. Caller must hold `mu`.
+func equipRod(player *Player, rodID string) bool {
+    if rodID == "" {
+        player.EquippedRod = ""
+        return true
+    }
+    if _, ok := rodStats[rodID]; !ok || !hasItem(player, rodID) {
+        return false
+    }
+    player.EquippedRod = rodID
+    return true
+}
+
+// **Handle Equip Rod**
+// Processes an `equipRod` action. Everyone else sees the new rod in the next snapshot.
+func handleEquipRod(req Request, equip *EquipRodRequest) {
+    player, rodID := req.Player, equip.RodID
+    mu.Lock()
+    ok := equipRod(player, rodID)
+    if ok {
+        markDirtyLocked(player.ID)
+    }
+    mu.Unlock()
+
+    if !ok {
+        WarningLogger.Printf("Player %s tried to equip unavailable rod %q", player.ID, rodID)
+        sendError(player, req.RequestID, ErrCodeNotOwned, "You don't own that rod!")
+        return
+    }
+    if rodID == "" {
+        DebugLogger.Printf("Player %s put their rod away", player.ID)
+    } else {
+        DebugLogger.Printf("Player %s equipped %s", player.ID, rodID)
+    }
+
+    if err := player.Send(Message{Type: "playerUpdate", Player: player, RequestID: req.RequestID}); err != nil {
+        ErrorLogger.Printf("Error sending rod update to player %s: %v", player.ID, err)
+    }
+}
+
+// **Wait Time**
+// Picks how long until a fish bites for this rod.
+func (r RodStats) waitTime() time.Duration {
+    if r.MaxWait <= r.MinWait {
+        return r.MinWait
+    }
+    return r.MinWait + time.Duration(rand.Int63n(int64(r.MaxWait-r.MinWait)))
+}
+
+// **Fish Weight**
+// The draw weight of a fish for this rod, or 0 if the rod can't land it.
+func (r RodStats) fishWeight(fish Fish) float64 {
+    if fish.Tier > r.Level {
+        return 0
+    }
+    weight := float64(fish.Rarity)
+    if fish.Rarity <= rareFishRarity {
+        weight *= 1 + r.Luck
+    }
+    return weight
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
@@ -10,8 +10,6 @@ import (
 	"sync"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
-
 	"github.com/gorilla/websocket"
 )
 
@@ -29,36 +27,21 @@ func init() {
     WarningLogger = log.New(os.Stdout, "WARNING: ", log.Ldate|log.Ltime|log.Lshortfile)
     ErrorLogger = log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
     DebugLogger = log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
-
-    	// Replace with your MySQL connection details
-	dsn := "root:Ele!10126593@tcp(127.0.0.1:3306)/fish_pals"
-	var err error
-	db, err = sql.Open("mysql", dsn)
-	if err != nil {
-		ErrorLogger.Fatalf("Error connecting to database: %v", err)
-	}
-
-	// Test the database connection
-	err = db.Ping()
-	if err != nil {
-		ErrorLogger.Fatalf("Database connection failed: %v", err)
-	}
-
-	InfoLogger.Println("MySQL database connection established")
 }
 
 // **WebSocket Upgrader**
 // Upgrades HTTP connections to WebSocket connections.
-// The `CheckOrigin` function is set to allow connections from any origin.
-// In a production environment, you should restrict this for security.
+// Origins are checked against `server.allowedOrigins` in the config. Browsers
+// always send one, so a request without is from a bot or tool and let through.
 var upgrader = websocket.Upgrader{
     CheckOrigin: func(r *http.Request) bool {
-        return true
+        origin := r.Header.Get("Origin")
+        return origin == "" || checkOrigin(origin)
     },
 }
 
 // **Synchronization Mutex**
-// A mutex to protect shared resources like the `players` map and `fishingChannels`.
+// A mutex to protect shared resources like the `players` map and player state.
 var mu sync.Mutex
 
 // **Players Map**
@@ -69,12 +52,6 @@ var players = make(map[string]*Player)
 // A 2D slice representing the game world grid, consisting of tiles.
 var gameMap [][]Tile
 
-// **Broadcast Channel**
-// A channel to handle incoming messages from players.
-// The server listens to this channel and processes messages accordingly.
-var broadcast = make(chan Message)
-
-var db *sql.DB
 
 // **Message Structure**
 // Defines the format of messages exchanged between server and clients.
@@ -82,29 +59,42 @@ type Message struct {
     Type   string      `json:"type"`   // Type of message (e.g., "join", "move", "action")
     Player *Player     `json:"player"` // Player data associated with the message
     Data   interface{} `json:"data"`   // Additional data (e.g., movement direction, action details)
+    RequestID string   `json:"requestId,omitempty"` // ID of the request this replies to, if it had one
 }
 
 // **Player Structure**
 // Represents each connected player.
 type Player struct {
     ID        string          `json:"id"`           // Unique identifier for the player
-    Conn      *websocket.Conn `json:"-"`            // WebSocket connection (not serialized to JSON)
+    Client    *Client         `json:"-"`            // Queued WebSocket connection (not serialized to JSON)
     X         int             `json:"x"`            // X-coordinate on the game map
     Y         int             `json:"y"`            // Y-coordinate on the game map
     Direction string          `json:"direction"`    // Direction the player is facing
     FacingWater bool          `json:"facingWater"`  // Returns if player is facing water
 	Inventory []Item          `json:"inventory"`    // Inventory of fish the player has
     Balance   int             `json:"balance"`      // User's money
+    EquippedRod string        `json:"equippedRod"`  // Catalog ID of the rod in hand, empty for none
+    Idle      bool            `json:"idle"`         // Disconnected but may still resume their session
+    AFK       bool            `json:"afk"`          // Connected but hasn't sent anything for a while
+    Records   map[string]Catch `json:"records,omitempty"` // Heaviest catch of each species by catalog ID
+    lastActive time.Time      // When the player last sent a request, guarded by `mu`
+    savedSeq  uint64          // Save sequence of the newest store write, guarded by `saveMu`
 }
 
 // **Item Structure**
-// Represents each item the player has in their inventory.
+// Represents each item the player has in their inventory, either a stack of
+// plain goods or a unique instance, see inventory.go.
 type Item struct {
+    UID    string `json:"uid,omitempty"` // Instance ID, stable for as long as the player has the item
     Type   string `json:"type"`
     Name   string `json:"name"`
     Quantity int   `json:"quantity"`
     Value  int    `json:"value"`
     Img    string `json:"img"`
+    ID     string `json:"id"`  // Catalog ID, see catalog.go
+    Catch  *Catch `json:"catch,omitempty"` // Size and quality of a caught fish, see catches.go
+    Rod    *RodState `json:"rod,omitempty"` // Wear and upgrades of a rod, see rods.go
+    AcquiredAt int64 `json:"acquiredAt,omitempty"` // When the item entered the inventory, Unix milliseconds
 }
 
 
@@ -120,54 +110,141 @@ type Tile struct {
 // **Fish Structure**
 // Represents fish that can be caught by players.
 type Fish struct {
-    Type   string `json:"type"`
-    Name   string `json:"name"`   // Name of the fish
-    Rarity int    `json:"rarity"` // Rarity of the fish (lower is rarer)
-    Value  int    `json:"value"`  // Monetary value of the fish
-    Img    string `json:"img"`    // Image path of the fish
+    Type      string        `json:"type"`
+    Name      string        `json:"name"`     // Name of the fish
+    Rarity    int           `json:"rarity"`   // Rarity of the fish (lower is rarer)
+    Value     int           `json:"value"`    // Monetary value of the fish
+    Img       string        `json:"img"`      // Image path of the fish
+    Tier      int           `json:"tier"`     // Minimum rod level needed to catch it
+    Strength  float64       `json:"strength"` // How hard it fights on the line (0-1)
+    FightTime time.Duration `json:"-"`        // Time the line has to stay in the tension band to land it
 }
 
 // **Predefined List of Fish**
 // A slice containing different types of fish available in the game.
+// Higher tiers need a better rod, see rods.go.
 var fishList = []Fish{
-    {"Fish", "Redfish", 10, 15, "./assets/redfish.png"},
-    {"Fish", "Commonfish", 95, 2, "./assets/commonfish.png"},
-    {"Fish", "Guppie", 90, 1, "./assets/guppie.png"},
-    {"Fish", "Clownfish", 5, 20, "./assets/clownfish.png"},
-    {"Fish", "Rarefish", 1, 100, "./assets/rarefish.png"},
+    {"Fish", "Redfish", 10, 15, "./assets/redfish.png", 0, 0.35, 3 * time.Second},
+    {"Fish", "Commonfish", 95, 2, "./assets/commonfish.png", 0, 0.1, 1500 * time.Millisecond},
+    {"Fish", "Guppie", 90, 1, "./assets/guppie.png", 0, 0.05, 1 * time.Second},
+    {"Fish", "Clownfish", 5, 20, "./assets/clownfish.png", 0, 0.3, 2500 * time.Millisecond},
+    {"Fish", "Rarefish", 1, 100, "./assets/rarefish.png", 0, 0.6, 4 * time.Second},
+    {"Fish", "Tuna", 8, 50, "./assets/commonfish.png", 1, 0.5, 4 * time.Second},
+    {"Fish", "Swordfish", 4, 150, "./assets/redfish.png", 2, 0.7, 5 * time.Second},
+    {"Fish", "Golden Koi", 2, 500, "./assets/clownfish.png", 3, 0.75, 5 * time.Second},
+    {"Fish", "Leviathan", 1, 2000, "./assets/rarefish.png", 4, 0.9, 7 * time.Second},
 }
 
 var poleList =[]Item{
-    {"Pole", "Half Decent Rod", 1, 100, "./assets/rod-half-decent"},
-    {"Pole", "Solid Rod n' Reel", 1, 1000, "./assets/rod-solid"},
-    {"Pole", "The Fishinator 2.0", 1, 2000, "./assets/rod-fishinator"},
-    {"Pole", "Rocket Rod", 1, 10000, "./assets/rod-rocket"},
+    {Type: "Pole", Name: "Half Decent Rod", Quantity: 1, Value: 100, Img: "./assets/rod-half-decent", ID: "rod-half-decent"},
+    {Type: "Pole", Name: "Solid Rod n' Reel", Quantity: 1, Value: 1000, Img: "./assets/rod-solid", ID: "rod-solid"},
+    {Type: "Pole", Name: "The Fishinator 2.0", Quantity: 1, Value: 2000, Img: "./assets/rod-fishinator", ID: "rod-fishinator"},
+    {Type: "Pole", Name: "Rocket Rod", Quantity: 1, Value: 10000, Img: "./assets/rod-rocket", ID: "rod-rocket"},
 }
 
-// **Fishing Channels Map**
-// Stores channels for each player engaged in fishing.
-// Used to communicate between the fishing process and the player's catch attempt.
-var fishingChannels = make(map[string]chan bool)
-
-
 // **Main Function**
 // Entry point of the server application.
 func main() {
     DebugLogger.Println("Starting server initialization")
     rand.Seed(time.Now().UnixNano())
+
+    var args []string
+    var err error
+    config, args, err = loadConfig(os.Args[1:])
+    if err != nil {
+        ErrorLogger.Fatalf("Failed to load configuration: %v", err)
+    }
+
+    // `fishserver [flags] migrate up|down|status` manages the schema and exits
+    if len(args) > 0 && args[0] == "migrate" {
+        if err := runMigrateCommand(config, args[1:]); err != nil {
+            ErrorLogger.Fatalf("Migration failed: %v", err)
+        }
+        return
+    }
+    // `fishserver [flags] account create <playerId>` gives a saved player an account and exits
+    if len(args) > 0 && args[0] == "account" {
+        if err := runAccountCommand(config, args[1:]); err != nil {
+            ErrorLogger.Fatalf("Account command failed: %v", err)
+        }
+        return
+    }
+    if len(args) > 0 {
+        ErrorLogger.Fatalf("Unknown command %q", args[0])
+    }
+    logConfig(config)
+    if allowsAnyOrigin() {
+        WarningLogger.Println("server.allowedOrigins allows any site to connect and log players in")
+    }
+    if config.Auth.ClaimSavedPlayers {
+        WarningLogger.Println("auth.claimSavedPlayers is on, anyone who knows a saved player's ID can register it")
+    }
+    applyFishingConfig(config.Fishing)
+    if err := initAuth(config.Auth); err != nil {
+        ErrorLogger.Fatalf("Failed to set up auth: %v", err)
+    }
+
+    store, err = openPlayerStore(config.Database.Driver, config.Database.DSN)
+    if err != nil {
+        ErrorLogger.Fatalf("Failed to open %s player store: %v", config.Database.Driver, err)
+    }
+    defer store.Close()
+    InfoLogger.Printf("Using %s player store", config.Database.Driver)
+
     generateGameMap()
-    go handleMessages()
-    go periodicSave(1 * time.Minute)
+    registerTickSystem(simulateReeling)
+    registerTickSystem(detectAFK)
+    go runGameLoop(config.Server.TickRate)
+    go periodicSave(config.Server.SaveInterval.Duration)
 
     http.HandleFunc("/ws", handleConnections)
-    fmt.Println("Server started on :8081")
-    DebugLogger.Println("Server listening on port 8081")
-    err := http.ListenAndServe(":8081", nil)
+    http.HandleFunc("/register", handleRegister)
+    http.HandleFunc("/login", handleLogin)
+    fmt.Println("Server started on", config.Server.Addr)
+    DebugLogger.Println("Server listening on", config.Server.Addr)
+    err = http.ListenAndServe(config.Server.Addr, nil)
     if err != nil {
         ErrorLogger.Println("Error starting server:", err)
     }
 }
 
+// **Load Or Create Player**
+// Loads the player's saved state, or creates and saves a new player on dry land.
+// Saving them straight away gives later item saves a player to belong to.
+func loadOrCreatePlayer(playerID string) (*Player, error) {
+    player, err := loadPlayerState(playerID)
+    if err == nil {
+        InfoLogger.Printf("Loaded player state for %s", playerID)
+        return player, nil
+    }
+    if !errors.Is(err, ErrPlayerNotFound) {
+        return nil, err
+    }
+
+    InfoLogger.Printf("No saved state for %s, creating new player", playerID)
+    player = &Player{ID: playerID, X: rand.Intn(config.World.Width), Y: rand.Intn(config.World.Height), Inventory: []Item{},}
+    for isTileWater(player) {
+        player.X = rand.Intn(config.World.Width)
+        player.Y = rand.Intn(config.World.Height)
+    }
+    DebugLogger.Printf("Assigned new starting position for player %s: (%d, %d)", playerID, player.X, player.Y)
+    if err := savePlayerState(player); err != nil {
+        return nil, err
+    }
+    return player, nil
+}
+
+// **Reject Join**
+// Replies to a failed `join` with an error and closes the connection, telling the client why.
+func rejectJoin(ws *websocket.Conn, codec Codec, requestID, code, reason string) {
+    ws.SetWriteDeadline(time.Now().Add(time.Second))
+    if data, err := codec.Marshal(Message{Type: "error", Data: ErrorReply{Code: code, RequestID: requestID, Message: reason}}); err == nil {
+        ws.WriteMessage(codec.FrameType(), data)
+    }
+    closeMsg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+    ws.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+}
+
 // **Handle WebSocket Connections**
 // Handles new incoming WebSocket connections from clients.
 func handleConnections(w http.ResponseWriter, r *http.Request) {
@@ -180,147 +257,245 @@ func handleConnections(w http.ResponseWriter, r *http.Request) {
     }
     defer ws.Close()
 
-    var msg Message
-    err = ws.ReadJSON(&msg)
-    if err != nil || msg.Type != "join" || msg.Player == nil {
-        WarningLogger.Println("Invalid join message")
+    watchHeartbeat(ws)
+    frameType, raw, err := ws.ReadMessage()
+    if err != nil {
+        WarningLogger.Printf("No join message from %s: %v", r.RemoteAddr, err)
+        return
+    }
+    joinCodec := codecForFrame(frameType)
+    env, join, err := decodeJoin(joinCodec, raw)
+    var protoErr *ProtocolError
+    if errors.As(err, &protoErr) {
+        WarningLogger.Printf("Invalid join message from %s: %v", r.RemoteAddr, err)
+        rejectJoin(ws, joinCodec, protoErr.RequestID, protoErr.Code, protoErr.Message)
+        return
+    }
+    version, err := negotiateVersion(join.Version)
+    if errors.As(err, &protoErr) {
+        WarningLogger.Printf("Rejected join from %s: %v", r.RemoteAddr, err)
+        rejectJoin(ws, joinCodec, env.RequestID, protoErr.Code, protoErr.Message)
         return
     }
+    codec := negotiateCodec(join.Codecs)
 
-    playerID := msg.Player.ID
+    playerID := join.PlayerID
+    if config.Auth.Required {
+        tokenPlayerID, err := verifyToken(join.Token)
+        if err != nil {
+            WarningLogger.Printf("Rejected join for %s from %s: %v", playerID, r.RemoteAddr, err)
+            rejectJoin(ws, joinCodec, env.RequestID, ErrCodeUnauthorized, err.Error())
+            return
+        }
+        if tokenPlayerID != playerID {
+            WarningLogger.Printf("Rejected join for %s from %s: token belongs to %s", playerID, r.RemoteAddr, tokenPlayerID)
+            rejectJoin(ws, joinCodec, env.RequestID, ErrCodeUnauthorized, "player id does not match session token")
+            return
+        }
+    }
     InfoLogger.Printf("Player joined: %s\n", playerID)
 
-    // Load or create player state
-    player, err := loadPlayerState(playerID)
+    client := newClient(ws, playerID, version, codec)
+    defer client.Close()
+
+    player, kind, err := attachSession(playerID, client, join.ResumeToken)
     if err != nil {
-        WarningLogger.Printf("Failed to load player state for %s: %v", playerID, err)
-        // Create a new player if not found
-        player = &Player{ID: playerID, Conn: ws, X: rand.Intn(50), Y: rand.Intn(50), Inventory: []Item{},}
-        for isTileWater(player) {
-            player.X = rand.Intn(50)
-            player.Y = rand.Intn(50)
-        }
-        DebugLogger.Printf("Assigned new starting position for player %s: (%d, %d)", playerID, player.X, player.Y)
-    } else {
-        player.Conn = ws
-        InfoLogger.Printf("Loaded player state for %s", playerID)
+        // Don't hand out a fresh player, saving it would overwrite their progress
+        ErrorLogger.Printf("Failed to load player state for %s: %v", playerID, err)
+        return
     }
 
-    mu.Lock()
-    players[playerID] = player
-    mu.Unlock()
-
-    sendInitialGameState(player)
-    notifyPlayerUpdate(player, "newPlayer")
+    // Other players hear about the join in the next snapshot
+    if kind == joinResumed {
+        // Missed messages were replayed, no full reload needed
+        InfoLogger.Printf("Player %s resumed their session", playerID)
+    } else {
+        sendInitialGameState(player)
+    }
 
     // Listen for messages from the player
+    limiter := sessionLimiter(playerID)
     for {
-        err := ws.ReadJSON(&msg)
+        frameType, raw, err := ws.ReadMessage()
+        if isTimeout(err) {
+            WarningLogger.Printf("Player %s stopped responding, dropping connection", playerID)
+            break
+        }
         if err != nil {
-            ErrorLogger.Printf("Error reading JSON from player %s: %v", playerID, err)
+            ErrorLogger.Printf("Error reading from player %s: %v", playerID, err)
             break
         }
-        msg.Player = player
-        broadcast <- msg
+        extendReadDeadline(ws)
+        received := time.Now()
+        req, err := decodeRequest(codecForFrame(frameType), raw, player)
+        requestID := req.RequestID
+        if errors.As(err, &protoErr) {
+            requestID = protoErr.RequestID
+        }
+        // Invalid messages count against the default budget
+        if !throttle(player, client, limiter, req.Type, requestID) {
+            continue
+        }
+        if errors.As(err, &protoErr) {
+            WarningLogger.Printf("Bad request from player %s: %v", playerID, err)
+            sendError(player, protoErr.RequestID, protoErr.Code, protoErr.Message)
+            continue
+        }
+        req.Received = received
+        req.Client = client
+        inputQueue <- req
     }
 
-    // Save player state on disconnect
     mu.Lock()
-    if err := savePlayerState(player); err != nil {
-        ErrorLogger.Printf("Failed to save player state for %s: %v", playerID, err)
-    } else {
-        InfoLogger.Printf("Successfully saved player state for %s", playerID)
+    if player.Client != client {
+        // A newer session took over the player and now owns saving it
+        mu.Unlock()
+        DebugLogger.Printf("Replaced session for player %s closed", playerID)
+        return
     }
-    delete(players, playerID)
+    if config.Server.ResumeGrace.Duration > 0 {
+        // Keep the player around in case they reconnect
+        detachSessionLocked(player)
+        mu.Unlock()
+        DebugLogger.Printf("Player %s disconnected, holding session for %v", playerID, config.Server.ResumeGrace.Duration)
+        return
+    }
+    removePlayerLocked(player)
+    notifyPlayerLeftLocked(playerID)
     mu.Unlock()
 
     DebugLogger.Printf("Player %s disconnected", playerID)
-    notifyPlayerLeft(playerID)
 }
 
+// **Remove Player (Locked)**
+// Saves the player and takes them out of the game. Their cast, if any, ends
+// on the next tick. Caller must hold `mu`.
+func removePlayerLocked(player *Player) {
+    if err := savePlayerStateLocked(player); err != nil {
+        ErrorLogger.Printf("Failed to save player state for %s: %v", player.ID, err)
+    }
+    delete(players, player.ID)
+    delete(sessions, player.ID)
+    unindexPlayerLocked(player.ID)
+    departedPlayers = append(departedPlayers, player)
+}
 
 
-func savePlayerState(player *Player) error {
-    InfoLogger.Printf("Successfully saved player state for %s", player.ID)
-    query := `
-        INSERT INTO players (player_id, x, y, direction, facing_water, balance)
-        VALUES (?, ?, ?, ?, ?, ?)
-        ON DUPLICATE KEY UPDATE x = VALUES(x), y = VALUES(y), direction = VALUES(direction), 
-                                facing_water = VALUES(facing_water), balance = VALUES(balance)
-    `
-    _, err := db.Exec(query, player.ID, player.X, player.Y, player.Direction, player.FacingWater, player.Balance)
-    if err != nil {
-        return fmt.Errorf("failed to save player state: %v", err)
-    }
 
-    // Save inventory
-    for _, item := range player.Inventory {
-        // Save inventory with the new 'type' column
-        invQuery := `
-            INSERT INTO inventory (player_id, item_name, quantity, value, img, type)
-            VALUES (?, ?, ?, ?, ?, ?)
-            ON DUPLICATE KEY UPDATE quantity = VALUES(quantity), value = VALUES(value), img = VALUES(img), type = VALUES(type)
-        `
-        _, err := db.Exec(invQuery, player.ID, item.Name, item.Quantity, item.Value, item.Img, item.Type)
-        if err != nil {
-            return fmt.Errorf("failed to save inventory for player %s: %v", player.ID, err)
+// **Save Ordering**
+// Snapshots copied under `mu` are written after it's released, so a slow one
+// could land on top of a newer write. Every write is numbered under `mu` and
+// a snapshot older than the player's last write is copied again first.
+var (
+    saveMu  sync.Mutex // Serializes checking `Player.savedSeq` with the write; taken after `mu`, never before
+    saveSeq uint64     // Last number handed out, guarded by `mu`
+)
+
+// **Player Snapshot**
+// A copy of a player taken under `mu`, to be saved once it's released.
+type playerSnapshot struct {
+    live  *Player // Copied again if the snapshot turns out stale
+    saved *Player
+    seq   uint64
+}
+
+// **Snapshot Player (Locked)**
+// Copies the player for `saveSnapshot`. Caller must hold `mu`.
+func snapshotPlayerLocked(player *Player) playerSnapshot {
+    saveSeq++
+    return playerSnapshot{live: player, saved: copyPlayer(player), seq: saveSeq}
+}
+
+// **Save Snapshot**
+// Writes the snapshot to the player store. If the player was written since it
+// was taken it's retaken, unless they've left and their final state is saved.
+// Caller must not hold `mu`.
+func saveSnapshot(snapshot playerSnapshot) error {
+    for {
+        saveMu.Lock()
+        if snapshot.live.savedSeq < snapshot.seq {
+            snapshot.live.savedSeq = snapshot.seq
+            err := savePlayerState(snapshot.saved)
+            saveMu.Unlock()
+            return err
         }
+        saveMu.Unlock()
+
+        mu.Lock()
+        if players[snapshot.live.ID] != snapshot.live {
+            mu.Unlock()
+            return nil
+        }
+        snapshot = snapshotPlayerLocked(snapshot.live)
+        mu.Unlock()
     }
-    return nil
 }
 
+// **Write Player (Locked)**
+// Runs a store write for the player that has to happen under `mu`, numbered
+// so snapshots taken before it don't overwrite it. Caller must hold `mu`.
+func writePlayerLocked(player *Player, write func() error) error {
+    saveSeq++
+    saveMu.Lock()
+    defer saveMu.Unlock()
+    player.savedSeq = saveSeq
+    return write()
+}
 
-func loadPlayerState(playerID string) (*Player, error) {
-    player := &Player{
-        ID: playerID,
-        Inventory: []Item{}, // Initialize inventory
-    }
+// **Save Player State (Locked)**
+// Writes the player's state and inventory while holding `mu`. Caller must hold `mu`.
+func savePlayerStateLocked(player *Player) error {
+    return writePlayerLocked(player, func() error { return savePlayerState(player) })
+}
 
-    // Load player state
-    query := `SELECT x, y, direction, facing_water, balance FROM players WHERE player_id = ?`
-    row := db.QueryRow(query, playerID)
-    if err := row.Scan(&player.X, &player.Y, &player.Direction, &player.FacingWater, &player.Balance); err != nil {
-        if err == sql.ErrNoRows {
-            return nil, fmt.Errorf("player not found")
-        }
-        return nil, fmt.Errorf("failed to load player state: %v", err)
+// **Save Player State**
+// Writes the player's state and inventory to the player store.
+func savePlayerState(player *Player) error {
+    if err := store.SavePlayer(player); err != nil {
+        return err
     }
+    InfoLogger.Printf("Successfully saved player state for %s", player.ID)
+    return nil
+}
 
-    // Load inventory 
-    invQuery := `SELECT item_name, quantity, value, img, IFNULL(type, 'Unknown') FROM inventory WHERE player_id = ?`
-    rows, err := db.Query(invQuery, playerID)
+// **Load Player State**
+// Reads a player from the player store, returning ErrPlayerNotFound for new players.
+func loadPlayerState(playerID string) (*Player, error) {
+    player, err := store.LoadPlayer(playerID)
     if err != nil {
-        return nil, fmt.Errorf("failed to load inventory: %v", err)
-    }
-    defer rows.Close()
-    
-    for rows.Next() {
-        var item Item
-        if err := rows.Scan(&item.Name, &item.Quantity, &item.Value, &item.Img, &item.Type); err != nil {
-            return nil, fmt.Errorf("failed to scan inventory item: %v", err)
-        }
-        player.Inventory = append(player.Inventory, item)
+        return nil, err
     }
 
+    // Stored value/img/type may be stale, the catalog is authoritative
+    player.Inventory = normalizeInventory(playerID, player.Inventory)
+
     return player, nil
 }
 
 
+// **Periodic Save**
+// Saves everyone in the game every `interval`, copying them under `mu` and
+// writing them once it's released.
 func periodicSave(interval time.Duration) {
 	for {
 		time.Sleep(interval)
 		mu.Lock()
+		snapshots := make([]playerSnapshot, 0, len(players))
 		for _, player := range players {
-			if err := savePlayerState(player); err != nil {
+			snapshots = append(snapshots, snapshotPlayerLocked(player))
+		}
+		mu.Unlock()
+		for _, snapshot := range snapshots {
+			if err := saveSnapshot(snapshot); err != nil {
 				ErrorLogger.Printf("Failed to save player state: %v", err)
 			}
 		}
-		mu.Unlock()
 	}
 }
 
 // **Send Initial Game State**
 // Sends the current game map and list of players to a newly connected player.
+// Later snapshots to the player are relative to the state sent here.
 func sendInitialGameState(player *Player) {
     mu.Lock()
     defer mu.Unlock()
@@ -331,10 +506,16 @@ func sendInitialGameState(player *Player) {
     }
 
     // Prepare the game state data
-    gameState := map[string]interface{}{
-        "gameMap":   gameMap,
-        "players":   getAllPlayers(),
-        "inventory": player.Inventory,
+    gameState := GameStateData{
+        Players:           visiblePlayerStatesLocked(player),
+        Inventory:         player.Inventory,
+        Balance:           player.Balance,
+        InventoryCapacity: config.Inventory.Capacity,
+    }
+    if player.Client != nil && player.Client.version >= tileGridVersion {
+        gameState.Tiles = compactTileGrid(gameMap)
+    } else {
+        gameState.GameMap = gameMap
     }
 
     // Create and send the initial game state message
@@ -343,114 +524,101 @@ func sendInitialGameState(player *Player) {
         Data:   gameState,
         Player: player,
     }
-    err := player.Conn.WriteJSON(initialMessage)
+    err := player.Send(initialMessage)
     if err != nil {
         ErrorLogger.Println("Error sending initial game state:", err)
+        return
     }
-}
-
-
-// **Get All Players**
-// Returns a slice of all players (excluding their WebSocket connections).
-func getAllPlayers() []*Player {
-    allPlayers := []*Player{}
-    for _, p := range players {
-        allPlayers = append(allPlayers, &Player{
-            ID:        p.ID,
-            X:         p.X,
-            Y:         p.Y,
-            Direction: p.Direction,
-        })
+    replicated := make(map[string]PlayerState, len(gameState.Players))
+    for _, state := range gameState.Players {
+        replicated[state.ID] = state
     }
-    return allPlayers
+    player.Client.replicated = replicated
 }
 
-// **Notify Player Update**
-// Sends a message to all other players about a new player or player updates.
-func notifyPlayerUpdate(player *Player, updateType string) {
-    mu.Lock()
-    defer mu.Unlock()
 
-    // Create the update message.
-    msg := Message{
-        Type:   updateType,
-        Player: &Player{
-            ID:        player.ID,
-            X:         player.X,
-            Y:         player.Y,
-            Direction: player.Direction,
-            FacingWater: player.FacingWater,
-            Balance:    player.Balance,
-        },
-    }
-
-    // Send the update message to all other players.
-    for id, p := range players {
-        if id != player.ID {
-            err := p.Conn.WriteJSON(msg)
-            if err != nil {
-                ErrorLogger.Printf("Error notifying player %s: %v\n", id, err)
-                p.Conn.Close()
-                delete(players, id)
-            }
-        }
-    }
-}
-
-// **Notify Player Left**
-// Informs all players that a player has disconnected.
-func notifyPlayerLeft(playerID string) {
-    mu.Lock()
-    defer mu.Unlock()
+// **Notify Player Left (Locked)**
+// Informs the players who could see a player that they disconnected, and
+// forgets them so they're sent in full if they come back. Caller must hold `mu`.
+func notifyPlayerLeftLocked(playerID string) {
+    delete(dirtyPlayers, playerID)
 
     // Create the player left message.
     msg := Message{
         Type: "playerLeft",
-        Data: playerID,
+        Data: PlayerLeftData{PlayerID: playerID},
     }
 
-    // Send the message to all other players.
+    // Send the message to the players who had them in view.
     for id, p := range players {
-        if id != playerID {
-            err := p.Conn.WriteJSON(msg)
-            if err != nil {
-                ErrorLogger.Printf("Error notifying player %s: %v\n", id, err)
-                p.Conn.Close()
-                delete(players, id)
-            }
+        if p.Client == nil {
+            continue
+        }
+        if _, seen := p.Client.replicated[playerID]; !seen {
+            continue
+        }
+        delete(p.Client.replicated, playerID)
+        if err := p.Client.Send(msg); err != nil {
+            ErrorLogger.Printf("Error sending playerLeft to player %s: %v", id, err)
         }
     }
 }
 
-// **Handle Messages**
-// Listens to the broadcast channel and processes incoming messages.
-func handleMessages() {
-    DebugLogger.Println("Message handler started")
-    for {
-        msg := <-broadcast
-        DebugLogger.Printf("Processing message: %+v", msg)
-        switch msg.Type {
-        case "move":
-            handleMove(msg)
-        case "action":
-            handleAction(msg)
-        case "catchAttempt":
-            handleCatchAttempt(msg)
-        default:
-            WarningLogger.Println("Unknown message type:", msg.Type)
-        }
+// **Handle Request**
+// Dispatches a request taken from the input queue by the game loop. Requests
+// from players who left, or from a connection no longer theirs, are dropped.
+func handleRequest(req Request) {
+    DebugLogger.Printf("Processing %s request from player %s: %+v", req.Type, req.Player.ID, req.Payload)
+    mu.Lock()
+    if players[req.Player.ID] != req.Player {
+        // Sent before the player left, anything it did would be lost or
+        // would overwrite whoever has the ID now
+        mu.Unlock()
+        DebugLogger.Printf("Dropping %s request from departed player %s", req.Type, req.Player.ID)
+        return
+    }
+    if req.Player.Client != req.Client {
+        // Queued by a connection that was replaced or dropped since, the
+        // session now belongs to another one
+        mu.Unlock()
+        DebugLogger.Printf("Dropping %s request from a stale connection of player %s", req.Type, req.Player.ID)
+        return
+    }
+    noteActivityLocked(req.Player, time.Now())
+    mu.Unlock()
+    switch payload := req.Payload.(type) {
+    case *MoveRequest:
+        handleMove(req, payload)
+    case *FishRequest:
+        handleFishing(req)
+    case *CatchAttemptRequest:
+        handleCatchAttempt(req)
+    case *ReelRequest:
+        handleReel(req, payload)
+    case *SellItemRequest:
+        handleSellItem(req, payload)
+    case *BuyItemRequest:
+        handleBuyItem(req, payload)
+    case *EquipRodRequest:
+        handleEquipRod(req, payload)
+    case *ShopCatalogRequest:
+        handleShopCatalog(req)
+    case *SortInventoryRequest:
+        handleSortInventory(req, payload)
+    case *RepairRodRequest:
+        handleRepairRod(req, payload)
+    case *UpgradeRodRequest:
+        handleUpgradeRod(req, payload)
+    default:
+        WarningLogger.Printf("No handler for %s request", req.Type)
     }
 }
 
 // **Handle Move**
 // Processes movement commands from players.
-func handleMove(msg Message) {
-    player := msg.Player
-    direction, ok := msg.Data.(string)
-    if !ok {
-        WarningLogger.Println("Invalid move data")
-        return
-    }
+func handleMove(req Request, move *MoveRequest) {
+    player := req.Player
+    direction := move.Direction
     DebugLogger.Printf("Player %s attempting to move %s", player.ID, direction)
 
     newX, newY := player.X, player.Y
@@ -470,26 +638,23 @@ func handleMove(msg Message) {
     }
 
     if isValidMove(newX, newY) {
+        // Walking off reels the line back in
+        abandonFishing(player)
         mu.Lock()
         player.X = newX
         player.Y = newY
         player.Direction = direction
         player.FacingWater = isTileWater(player)
+        indexPlayerLocked(player)
+        // Sent to everyone in view in this tick's snapshot
+        markDirtyLocked(player.ID)
         mu.Unlock()
         DebugLogger.Printf("Player %s moved to (%d, %d), facing %s", player.ID, newX, newY, direction)
 
-        movementMessage := Message{
-            Type:   "playerUpdate",
-            Player: player,
-        }
-        broadcastMessageToAll(movementMessage)
+        sendAck(player, req.RequestID)
     } else {
         DebugLogger.Printf("Invalid move attempt by player %s to (%d, %d)", player.ID, newX, newY)
-        errMsg := Message{
-            Type: "error",
-            Data: "Invalid move",
-        }
-        player.Conn.WriteJSON(errMsg)
+        sendError(player, req.RequestID, ErrCodeInvalidMove, "Invalid move")
     }
 }
 
@@ -505,182 +670,65 @@ func isValidMove(x, y int) bool {
     return true
 }
 
-// **Handle Action**
-// Processes action commands from players (e.g., fishing).
-func handleAction(msg Message) {
-    player := msg.Player
-    actionData, ok := msg.Data.(map[string]interface{})
-    if !ok {
-        WarningLogger.Println("Invalid action data")
-        return
-    }
-    actionType, ok := actionData["actionType"].(string)
-    if !ok {
-        WarningLogger.Println("Invalid action type")
-        return
-    }
-    DebugLogger.Printf("Player %s attempting action: %s", player.ID, actionType)
-    switch actionType {
-    case "fish":
-        handleFishing(player)
-    case "sellItem":
-        itemData, ok := actionData["item"].(map[string]interface{})
-        if !ok {
-            WarningLogger.Println("Invalid item data for sell action")
-            return
-        }
-        item, err := mapToItem(itemData)
-        if err != nil {
-            WarningLogger.Printf("Error converting item data: %v", err)
-            return
-        }
-        handleSellItem(player, item)
-    default:
-        WarningLogger.Println("Unknown action type:", actionType)
-    }
-}
-
+// **Handle Selling Items**
+// Handles selling the player's item and adds to their balance
+func handleSellItem(req Request, sell *SellItemRequest) {
+    player := req.Player
+    DebugLogger.Printf("Entered handleSellItem for player %s with item: %+v\n", player.ID, sell)
 
+    mu.Lock()
+    defer mu.Unlock()
 
-// **Handle Fishing**
-// Initiates the fishing process for the player.
-func handleFishing(player *Player) {
-    facingX, facingY := getFacingTile(player)
-    DebugLogger.Printf("Player %s attempting to fish at (%d, %d)", player.ID, facingX, facingY)
-    if !isWithinBounds(facingX, facingY) || gameMap[facingY][facingX].Type != 0 {
-        DebugLogger.Printf("Invalid fishing attempt by player %s", player.ID)
-        errMsg := Message{
-            Type: "error",
-            Data: "You can't fish here!",
-        }
-        player.Conn.WriteJSON(errMsg)
+    if replayTradeLocked(req) {
         return
     }
 
-    go startFishingProcess(player)
-}
-
-// **Handle Catch Attempt**
-// Handles the player's attempt to catch a fish.
-func handleCatchAttempt(msg Message) {
-    player := msg.Player
-    mu.Lock()
-    if ch, ok := fishingChannels[player.ID]; ok {
-        ch <- true // Signal that the player attempted to catch the fish.
-    }
-    mu.Unlock()
-}
-
-func checkInventoryForSale(player *Player, itemToSell Item) (bool, error) {
-    mu.Lock()
-    defer mu.Unlock()
-
-    for _, inventoryItem := range player.Inventory {
-        if inventoryItem.Name == itemToSell.Name {
-            if inventoryItem.Quantity >= itemToSell.Quantity {
-                return true, nil
-            } else {
-                return false, fmt.Errorf("insufficient quantity: have %d, want to sell %d", inventoryItem.Quantity, itemToSell.Quantity)
-            }
+    // A specific item is sold as whatever it is
+    itemID := sell.ItemID
+    if sell.UID != "" {
+        i := findItem(player.Inventory, sell.UID)
+        if i < 0 {
+            sendError(player, req.RequestID, ErrCodeNotOwned, "item not found in inventory")
+            return
         }
+        itemID = player.Inventory[i].ID
     }
-    return false, fmt.Errorf("item not found in inventory")
-}
-
-// **Handle Selling Items**
-// Handles selling the player's item and adds to their balance
-// Updated handleSellItem function
-func handleSellItem(player *Player, item Item) {
-    DebugLogger.Printf("Entered handleSellItem for player %s with item: %+v\n", player.ID, item)
 
-    canSell, err := checkInventoryForSale(player, item)
+    // Value, img and type always come from the catalog
+    entry, err := lookupCatalogItem(itemID, "")
     if err != nil {
-        ErrorLogger.Printf("Error checking inventory for player %s: %v", player.ID, err)
-        errorMessage := Message{
-            Type: "error",
-            Data: err.Error(),
-        }
-        _ = player.Conn.WriteJSON(errorMessage)
+        WarningLogger.Printf("Invalid sale by player %s: %v", player.ID, err)
+        sendError(player, req.RequestID, ErrCodeInvalidItem, err.Error())
         return
     }
-
-    DebugLogger.Printf("Can sell item: %t\n", canSell)
-
-    if canSell {
-        mu.Lock()
-        defer mu.Unlock()
-
-        // Update player balance
-        addToPlayerBalance(player, item)
-
-        // Update player inventory
-        removeItemFromInventory(player, item)
-
-        DebugLogger.Printf("Successfully sold item for player %s. New balance: %d\n", player.ID, player.Balance)
-
-        // Send inventory update message
-        inventoryMessage := Message{
-            Type:   "inventoryUpdate",
-            Player: player,
-            Data:   player.Inventory,
-        }
-        if err := player.Conn.WriteJSON(inventoryMessage); err != nil {
-            ErrorLogger.Printf("Error sending inventory update to player %s: %v", player.ID, err)
-            return
-        }
-
-        // Send balance update message
-        balanceMessage := Message{
-            Type:   "playerUpdate",
-            Player: player,
-        }
-        if err := player.Conn.WriteJSON(balanceMessage); err != nil {
-            ErrorLogger.Printf("Error sending balance update to player %s: %v", player.ID, err)
-            return
-        }
-
-        // Send sell event message
-        sellMessage := Message{
-            Type:   "sellEvent",
-            Player: player,
-            Data: map[string]interface{}{
-                "event":    "itemSold",
-                "playerId": player.ID,
-                "item":     item,
-            },
-        }
-        if err := player.Conn.WriteJSON(sellMessage); err != nil {
-            ErrorLogger.Printf("Error sending sell message to player %s: %v", player.ID, err)
-        }
-        savePlayerState(player)
+    earned, sold, err := sellItem(player, entry, sell.UID, sell.Quantity)
+    var tradeErr *TradeFailedError
+    switch {
+    case errors.As(err, &tradeErr):
+        sendError(player, req.RequestID, ErrCodeTradeFailed, err.Error())
+        return
+    case err != nil:
+        ErrorLogger.Printf("Error selling item for player %s: %v", player.ID, err)
+        sendError(player, req.RequestID, ErrCodeNotOwned, err.Error())
+        return
     }
-}
-
 
-// **Add to Player Balance**
-// Adds the total value of the sold items to the player's balance
-func addToPlayerBalance(player *Player, item Item) {
-    totalValue := item.Value * item.Quantity // Calculate total value
-    DebugLogger.Printf("DEBUG: Adding total value to balance for player %s. Current balance: %d, Total value: %d", player.ID, player.Balance, totalValue)
+    DebugLogger.Printf("Successfully sold item for player %s. New balance: %d\n", player.ID, player.Balance)
 
-    player.Balance += totalValue
-
-    // Update balance in the database
-    balQuery := `
-        UPDATE players 
-        SET balance = ? 
-        WHERE player_id = ?
-    `
-    _, err := db.Exec(balQuery, player.Balance, player.ID)
-    if err != nil {
-        ErrorLogger.Printf("Failed to update balance in DB for player %s: %v", player.ID, err)
+    sellMessage := Message{
+        Type:   "sellEvent",
+        Player: player,
+        Data: SellEventData{
+            Event:    "itemSold",
+            PlayerID: player.ID,
+            Items:    sold,
+            Earned:   earned,
+        },
     }
-
-    DebugLogger.Printf("DEBUG: New balance for player %s: %d", player.ID, player.Balance)
+    rememberReplyLocked(req, sellMessage)
+    sendTradeResultLocked(req, sellMessage)
 }
 
-// TODO - create subtract from player balance
-// TODO - create functionality to send what items are available to buy, keep in mind that eventually we'll be locking certain items behind progression checks
 
 // **Get Facing Tile**
 // Determines the tile in front of the player based on their direction.
@@ -722,261 +770,43 @@ func isTileWater(player *Player) bool {
     return false
 }
 
-func mapToItem(m map[string]interface{}) (Item, error) {
-    item := Item{}
-    
-    if t, ok := m["type"].(string); ok {
-        item.Type = t
-    } else {
-        return item, fmt.Errorf("invalid type")
-    }
-    
-    if n, ok := m["name"].(string); ok {
-        item.Name = n
-    } else {
-        return item, fmt.Errorf("invalid name")
-    }
-    
-    if q, ok := m["quantity"].(float64); ok {
-        item.Quantity = int(q)
-    } else {
-        return item, fmt.Errorf("invalid quantity")
-    }
-    
-    if v, ok := m["value"].(float64); ok {
-        item.Value = int(v)
-    } else {
-        return item, fmt.Errorf("invalid value")
-    }
-    
-    if i, ok := m["img"].(string); ok {
-        item.Img = i
-    } else {
-        return item, fmt.Errorf("invalid img")
-    }
-    
-    return item, nil
-
-}
-// **Start Fishing Process**
-// Simulates the fishing process, including waiting for a fish to bite and handling the catch attempt.
-func startFishingProcess(player *Player) {
-    DebugLogger.Printf("Starting fishing process for player %s", player.ID)
-    timeToCatch := time.Duration(rand.Intn(5)+1) * time.Second
-    time.Sleep(timeToCatch)
-
-    biteChance := 0.8
-    if rand.Float64() <= biteChance {
-        DebugLogger.Printf("Fish bite for player %s", player.ID)
-        biteMessage := Message{
-            Type:   "fishingEvent",
-            Player: player,
-            Data: map[string]interface{}{
-                "event":    "start",
-                "playerId": player.ID,
-            },
-        }
-        player.Conn.WriteJSON(biteMessage)
-
-        catchWindow := 3 * time.Second
-        responseChan := make(chan bool)
-        mu.Lock()
-        fishingChannels[player.ID] = responseChan
-        mu.Unlock()
-
-        select {
-        case <-responseChan:
-            DebugLogger.Printf("Player %s attempted to catch fish", player.ID)
-            caughtFish := selectRandomFish()
-            DebugLogger.Printf("Player %s caught %s", player.ID, caughtFish.Name)
-
-            addItemToInventory(player, Item{
-                Type:     caughtFish.Type,
-                Name:     caughtFish.Name,
-                Quantity: 1,
-                Value:    caughtFish.Value,
-                Img:      caughtFish.Img,
-            })
-
-            savePlayerState(player)
-
-            inventoryMessage := Message{
-                Type:   "inventoryUpdate",
-                Player: player,
-                Data:   player.Inventory,
-            }
-            player.Conn.WriteJSON(inventoryMessage)
-
-            catchMessage := Message{
-                Type:   "fishingEvent",
-                Player: player,
-                Data: map[string]interface{}{
-                    "event":    "catch",
-                    "playerId": player.ID,
-                    "fish":     caughtFish,
-                },
-            }
-            player.Conn.WriteJSON(catchMessage)
-
-        case <-time.After(catchWindow):
-            DebugLogger.Printf("Player %s failed to catch fish (timeout)", player.ID)
-            timeoutMessage := Message{
-                Type:   "fishingEvent",
-                Player: player,
-                Data: map[string]interface{}{
-                    "event":    "fail",
-                    "playerId": player.ID,
-                },
-            }
-            player.Conn.WriteJSON(timeoutMessage)
-        }
-
-        mu.Lock()
-        delete(fishingChannels, player.ID)
-        mu.Unlock()
-    } else {
-        DebugLogger.Printf("No fish bite for player %s", player.ID)
-        noBiteMessage := Message{
-            Type:   "fishingEvent",
-            Player: player,
-            Data: map[string]interface{}{
-                "event":    "fail",
-                "playerId": player.ID,
-            },
-        }
-        player.Conn.WriteJSON(noBiteMessage)
-    }
-}
-
-
-func addItemToInventory(player *Player, item Item) {
-    mu.Lock()
-    defer mu.Unlock()
-
-    // Ensure inventory is initialized
-    if player.Inventory == nil {
-        player.Inventory = []Item{}
-    }
-
-    // Check if the item already exists in the inventory
-    for i, invItem := range player.Inventory {
-        if invItem.Name == item.Name {
-            player.Inventory[i].Quantity += item.Quantity
-            // Update database...
-            return
-        }
-    }
-
-    // If the item doesn't exist, add it to the inventory
-    player.Inventory = append(player.Inventory, item)
-
-    // Insert the new item into the database
-    invQuery := `
-        INSERT INTO inventory (player_id, item_name, quantity, value, img, type)
-        VALUES (?, ?, ?, ?, ?, ?)
-    `
-    _, err := db.Exec(invQuery, player.ID, item.Name, item.Quantity, item.Value, item.Img, item.Type)
+// **Add Item To Inventory (Locked)**
+// Adds the item to the player's inventory, failing with `ErrInventoryFull`
+// if it needs a slot and there are none left. The caller saves the player.
+// Caller must hold `mu`.
+func addItemToInventoryLocked(player *Player, item Item) error {
+    inventory, _, err := addItem(player.Inventory, item)
     if err != nil {
-        ErrorLogger.Printf("Failed to add new item to DB for player %s: %v", player.ID, err)
+        return err
     }
+    player.Inventory = inventory
+    return nil
 }
 
-
-func removeItemFromInventory(player *Player, item Item) {
-    DebugLogger.Println("Entered removeItemFromInventory")
-
-    // Iterate through the inventory
-    for i := 0; i < len(player.Inventory); i++ {
-        invItem := player.Inventory[i]
-
-        if invItem.Name == item.Name {
-            DebugLogger.Printf("Found item in inventory: %+v", invItem)
-
-            // Ensure the quantity to remove does not exceed available quantity
-            if item.Quantity > invItem.Quantity {
-                DebugLogger.Printf("Error: Attempted to remove %d but only %d available", item.Quantity, invItem.Quantity)
-                return
-            }
-
-            // Decrease the quantity
-            player.Inventory[i].Quantity -= item.Quantity
-            DebugLogger.Printf("Updated quantity of %s: %d", invItem.Name, player.Inventory[i].Quantity)
-
-            // Update the inventory in the database
-            invQuery := `
-                UPDATE inventory 
-                SET quantity = ? 
-                WHERE player_id = ? AND item_name = ?
-            `
-            _, err := db.Exec(invQuery, player.Inventory[i].Quantity, player.ID, item.Name)
-            if err != nil {
-                ErrorLogger.Printf("Failed to update inventory in DB for player %s: %v", player.ID, err)
-            }
-
-            // Remove the item if the quantity reaches 0
-            if player.Inventory[i].Quantity <= 0 {
-                DebugLogger.Printf("Quantity of %s reached 0, removing from inventory", invItem.Name)
-                player.Inventory = append(player.Inventory[:i], player.Inventory[i+1:]...)
-
-                // Delete the item from the database
-                delQuery := `
-                    DELETE FROM inventory 
-                    WHERE player_id = ? AND item_name = ?
-                `
-                _, err := db.Exec(delQuery, player.ID, item.Name)
-                if err != nil {
-                    ErrorLogger.Printf("Failed to delete item from DB for player %s: %v", player.ID, err)
-                }
-            }
-            return
-        }
-    }
-
-    DebugLogger.Printf("Item %s not found in inventory, no changes made", item.Name)
-}
-
-
-
-
-
 // **Select Random Fish**
-// Randomly selects a fish based on their rarity.
-func selectRandomFish() Fish {
-    totalWeight := 0
+// Randomly selects a fish the rod can land, weighted by rarity and rod luck.
+func selectRandomFish(rod RodStats) Fish {
+    totalWeight := 0.0
     for _, fish := range fishList {
-        totalWeight += fish.Rarity
+        totalWeight += rod.fishWeight(fish)
     }
-    randNum := rand.Intn(totalWeight)
+    randNum := rand.Float64() * totalWeight
     for _, fish := range fishList {
-        if randNum < fish.Rarity {
+        weight := rod.fishWeight(fish)
+        if randNum < weight {
             return fish
         }
-        randNum -= fish.Rarity
-    }
-    // Default to the last fish if none is selected.
-    return fishList[len(fishList)-1]
-}
-
-// **Broadcast Message To All**
-// Sends a message to all connected players.
-func broadcastMessageToAll(msg Message) {
-    mu.Lock()
-    defer mu.Unlock()
-    for _, player := range players {
-        err := player.Conn.WriteJSON(msg)
-        if err != nil {
-            ErrorLogger.Printf("Error broadcasting to player %s: %v\n", player.ID, err)
-            player.Conn.Close()
-            delete(players, player.ID)
-        }
+        randNum -= weight
     }
+    // Default to the first fish if none is selected.
+    return fishList[0]
 }
 
 // **Generate Game Map**
 // Initializes the game map and adds water and sand layers.
 func generateGameMap() {
-    width := 50  // Width of the map
-    height := 50 // Height of the map
+    width := config.World.Width   // Width of the map
+    height := config.World.Height // Height of the map
     gameMap = initializeMap(width, height)
     addWaterLayer(gameMap)
     addSandLayer(gameMap)
@@ -0,0 +1,220 @@
+package main
+
+import (
+    "crypto/rand"
+    "crypto/subtle"
+    "encoding/hex"
+    "time"
+)
+
+// **Session Structure**
+// Connection state for an online player that outlives a single WebSocket.
+type session struct {
+    resumeToken string               // Lets a reconnect pick up where the player left off
+    expiry      *time.Timer          // Removes the player if they don't come back, nil while connected
+    replies     map[replyKey]Message // Results of recent economy requests by type and request ID
+    replyOrder  []replyKey           // Keys in `replies`, oldest first
+    limiter     *rateLimiter         // Message budgets, shared by every connection of the session
+}
+
+// **Reply Key**
+// Identifies a remembered reply. The type is part of it so a request that
+// reuses another type's ID is never answered with the other's result.
+type replyKey struct {
+    Type      string
+    RequestID string
+}
+
+// **Remembered Replies**
+// How many economy results are kept per session for retried requests.
+const rememberedReplies = 64
+
+// **Sessions**
+// Sessions of online players by ID. Guarded by `mu`.
+var sessions = make(map[string]*session)
+
+// **Join Kind**
+// How a connection became the player's session.
+type joinKind int
+
+const (
+    joinNew      joinKind = iota // The player wasn't online
+    joinReplaced                 // Took over a live or disconnected session
+    joinResumed                  // Resumed a disconnected session, missed messages replayed
+)
+
+// **New Resume Token**
+// A random single-use token, rotated every time a session is attached.
+func newResumeToken() string {
+    b := make([]byte, 24)
+    if _, err := rand.Read(b); err != nil {
+        // Without a token the client just can't resume, which is safe
+        ErrorLogger.Printf("Failed to generate resume token: %v", err)
+        return ""
+    }
+    return hex.EncodeToString(b)
+}
+
+// **Attach Session**
+// Makes `client` the player's connection and adds them to the game. If the
+// player is already online the in-memory player is handed over as is, since
+// it's newer than anything saved. A live older connection gets a
+// `sessionReplaced` message and is closed. A disconnected one is resumed when
+// `resumeToken` matches and no messages were dropped, replaying what they missed.
+func attachSession(playerID string, client *Client, resumeToken string) (*Player, joinKind, error) {
+    mu.Lock()
+    player, online := players[playerID]
+    mu.Unlock()
+
+    if !online {
+        loaded, err := loadOrCreatePlayer(playerID)
+        if err != nil {
+            return nil, joinNew, err
+        }
+        player = loaded
+    }
+
+    mu.Lock()
+    defer mu.Unlock()
+
+    // Another session may have joined while we were loading
+    if live, ok := players[playerID]; ok {
+        player, online = live, true
+    }
+
+    sess, ok := sessions[playerID]
+    if !ok {
+        sess = &session{}
+        sessions[playerID] = sess
+    }
+    if sess.expiry != nil {
+        sess.expiry.Stop()
+        sess.expiry = nil
+    }
+
+    kind := joinNew
+    // Hold clientMu throughout so nothing is sent to the old client after it's drained
+    clientMu.Lock()
+    if online {
+        kind = joinReplaced
+        old := player.Client
+        switch {
+        case old == nil:
+        case old.conn != nil:
+            InfoLogger.Printf("Player %s joined again, replacing the older session", playerID)
+            old.Kick(Message{Type: "sessionReplaced", Data: NoticeData{Message: "You logged in from somewhere else"}}, "session replaced")
+        default:
+            tokenOK := resumeToken != "" && subtle.ConstantTimeCompare([]byte(resumeToken), []byte(sess.resumeToken)) == 1
+            // The missed messages are encoded for the old connection's codec
+            sameCodec := old.codec == client.codec && old.version == client.version
+            if missed, complete := old.takeQueued(); tokenOK && sameCodec && complete {
+                kind = joinResumed
+                for _, data := range missed {
+                    client.sendRaw(data)
+                }
+            }
+            old.Close()
+        }
+    }
+    player.Client = client
+    clientMu.Unlock()
+
+    player.Idle = false
+    noteActivityLocked(player, time.Now())
+    players[playerID] = player
+    indexPlayerLocked(player)
+    // Sends the player to everyone in view who doesn't have them yet, and the idle change to those who do
+    markDirtyLocked(playerID)
+    if kind == joinResumed {
+        // Messages still queued for the dropped connection may never have arrived
+        client.resync = true
+    }
+    sess.resumeToken = newResumeToken()
+    client.Send(Message{
+        Type: "session",
+        Data: SessionData{
+            ProtocolVersion: client.version,
+            Codec:           client.codec.Name(),
+            ResumeToken:     sess.resumeToken,
+            ResumeGraceMs:   config.Server.ResumeGrace.Milliseconds(),
+            Resumed:         kind == joinResumed,
+        },
+    })
+    return player, kind, nil
+}
+
+// **Remember Reply (Locked)**
+// Keeps the result of a successful economy request so a retry of it isn't
+// applied twice. Retries are only sent after the session was resumed, so the
+// replies go with the session. Caller must hold `mu`.
+func rememberReplyLocked(req Request, reply Message) {
+    sess, ok := sessions[req.Player.ID]
+    if !ok || req.RequestID == "" {
+        return
+    }
+    key := replyKey{Type: req.Type, RequestID: req.RequestID}
+    if sess.replies == nil {
+        sess.replies = make(map[replyKey]Message)
+    }
+    if _, seen := sess.replies[key]; !seen {
+        sess.replyOrder = append(sess.replyOrder, key)
+    }
+    sess.replies[key] = reply
+    if len(sess.replyOrder) > rememberedReplies {
+        delete(sess.replies, sess.replyOrder[0])
+        sess.replyOrder = sess.replyOrder[1:]
+    }
+}
+
+// **Recall Reply (Locked)**
+// Returns the remembered result of an economy request. Caller must hold `mu`.
+func recallReplyLocked(req Request) (Message, bool) {
+    sess, ok := sessions[req.Player.ID]
+    if !ok || req.RequestID == "" {
+        return Message{}, false
+    }
+    reply, ok := sess.replies[replyKey{Type: req.Type, RequestID: req.RequestID}]
+    return reply, ok
+}
+
+// **Detach Session (Locked)**
+// Keeps a disconnected player in the game, marked idle, buffering messages
+// for them until they resume or the grace period runs out. Caller must hold `mu`.
+func detachSessionLocked(player *Player) {
+    player.Idle = true
+    markDirtyLocked(player.ID)
+    if err := savePlayerStateLocked(player); err != nil {
+        ErrorLogger.Printf("Failed to save player state for %s: %v", player.ID, err)
+    }
+
+    detached := newDetachedClient(player.Client)
+    clientMu.Lock()
+    player.Client = detached
+    clientMu.Unlock()
+
+    sess, ok := sessions[player.ID]
+    if !ok {
+        sess = &session{}
+        sessions[player.ID] = sess
+    }
+    sess.expiry = time.AfterFunc(config.Server.ResumeGrace.Duration, func() {
+        expireSession(player, detached)
+    })
+}
+
+// **Expire Session**
+// Removes a player who didn't reconnect within the grace period.
+func expireSession(player *Player, detached *Client) {
+    mu.Lock()
+    if player.Client != detached {
+        // They came back in the meantime
+        mu.Unlock()
+        return
+    }
+    detached.Close()
+    removePlayerLocked(player)
+    notifyPlayerLeftLocked(player.ID)
+    mu.Unlock()
+
+    InfoLogger.Printf("Session for player %s expired", player.ID)
+}
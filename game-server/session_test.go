@@ -0,0 +1,72 @@
+package main
+
+import (
+    "testing"
+)
+
+func TestReplyCacheKeyedByType(t *testing.T) {
+    player := &Player{ID: "replies"}
+    sessions[player.ID] = &session{}
+    defer delete(sessions, player.ID)
+
+    sell := Request{Type: "sellItem", RequestID: "r1", Player: player}
+    buy := Request{Type: "buyItem", RequestID: "r1", Player: player}
+    rememberReplyLocked(sell, Message{Type: "sellEvent"})
+
+    if reply, ok := recallReplyLocked(sell); !ok || reply.Type != "sellEvent" {
+        t.Fatalf("recall of the sell = %v, %v, want the sellEvent", reply.Type, ok)
+    }
+    if reply, ok := recallReplyLocked(buy); ok {
+        t.Fatalf("buy with the sell's request ID was answered with %s", reply.Type)
+    }
+}
+
+func TestReplyCacheForgetsOldest(t *testing.T) {
+    player := &Player{ID: "replies"}
+    sessions[player.ID] = &session{}
+    defer delete(sessions, player.ID)
+
+    first := Request{Type: "sellItem", RequestID: "first", Player: player}
+    rememberReplyLocked(first, Message{Type: "sellEvent"})
+    for i := 0; i < rememberedReplies; i++ {
+        rememberReplyLocked(Request{Type: "sellItem", RequestID: string(rune('a' + i)), Player: player}, Message{Type: "sellEvent"})
+    }
+    if _, ok := recallReplyLocked(first); ok {
+        t.Fatalf("oldest reply still remembered after %d newer ones", rememberedReplies)
+    }
+}
+
+// Requests still queued from a replaced connection must not act on the player.
+func TestStaleClientRequestDropped(t *testing.T) {
+    useStore(t, newMemoryStore())
+    player := savedTestPlayer(t, 100)
+    newTestClient := func() *Client {
+        return &Client{playerID: player.ID, codec: jsonCodec, send: make(chan []byte, 16), done: make(chan struct{})}
+    }
+    old, current := newTestClient(), newTestClient()
+    mu.Lock()
+    player.Client = current
+    players[player.ID] = player
+    mu.Unlock()
+    defer func() {
+        mu.Lock()
+        delete(players, player.ID)
+        mu.Unlock()
+    }()
+
+    sell := func(client *Client, requestID string) {
+        handleRequest(Request{Type: "sellItem", RequestID: requestID, Player: player, Client: client,
+            Payload: &SellItemRequest{ItemID: "commonfish", Quantity: 1}})
+    }
+    sell(old, "stale")
+    if got := quantityOf(player, "commonfish"); got != 12 {
+        t.Fatalf("%d Commonfish after the stale sell, want 12", got)
+    }
+    if len(old.send) != 0 || len(current.send) != 0 {
+        t.Errorf("stale sell was answered")
+    }
+    sell(current, "live")
+    if got := quantityOf(player, "commonfish"); got != 11 {
+        t.Errorf("%d Commonfish after the live sell, want 11", got)
+    }
+}
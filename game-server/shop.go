@@ -0,0 +1,232 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+)
+
+// **Shop Stock**
+// IDs of the catalog items players can buy. Currently the rods in `poleList`.
+var shopStock = buildShopStock()
+
+// **Build Shop Stock**
+// Lists every pole in `poleList` as purchasable.
+func buildShopStock() []string {
+    stock := make([]string, 0, len(poleList))
+    for _, pole := range poleList {
+        stock = append(stock, pole.ID)
+    }
+    return stock
+}
+
+// **Unlock Rule Structure**
+// A single requirement a player must meet before a shop item is unlocked.
+type UnlockRule struct {
+    Kind   string // "ownsItem" or "minBalance"
+    ItemID string // Catalog ID for "ownsItem"
+    Amount int    // Threshold for "minBalance"
+}
+
+// **Shop Unlock Rules**
+// Progression requirements per shop item. Items without rules are always unlocked.
+var shopUnlockRules = map[string][]UnlockRule{
+    "rod-solid":      {{Kind: "ownsItem", ItemID: "rod-half-decent"}},
+    "rod-fishinator": {{Kind: "ownsItem", ItemID: "rod-solid"}},
+    "rod-rocket": {
+        {Kind: "ownsItem", ItemID: "rod-fishinator"},
+        {Kind: "minBalance", Amount: 5000},
+    },
+}
+
+// **Is Met**
+// Checks the rule against the player's current state. Caller must hold `mu`.
+func (r UnlockRule) isMet(player *Player) bool {
+    switch r.Kind {
+    case "ownsItem":
+        return hasItem(player, r.ItemID)
+    case "minBalance":
+        return player.Balance >= r.Amount
+    default:
+        WarningLogger.Printf("Unknown unlock rule kind: %s", r.Kind)
+        return false
+    }
+}
+
+// **Describe**
+// Human readable form of the rule, shown to players while the item is locked.
+func (r UnlockRule) describe() string {
+    switch r.Kind {
+    case "ownsItem":
+        name := r.ItemID
+        if entry, ok := itemCatalog[r.ItemID]; ok {
+            name = entry.Name
+        }
+        return fmt.Sprintf("own %s", name)
+    case "minBalance":
+        return fmt.Sprintf("balance ≥ %d", r.Amount)
+    default:
+        return r.Kind
+    }
+}
+
+// **Unmet Requirements**
+// Lists the rules the player still has to meet for the item. Caller must hold `mu`.
+func unmetRequirements(player *Player, itemID string) []string {
+    unmet := []string{}
+    for _, rule := range shopUnlockRules[itemID] {
+        if !rule.isMet(player) {
+            unmet = append(unmet, rule.describe())
+        }
+    }
+    return unmet
+}
+
+// **Shop Listing Structure**
+// A shop item as seen by a specific player.
+type ShopListing struct {
+    CatalogItem
+    Locked       bool     `json:"locked"`       // True while any requirement is unmet
+    Requirements []string `json:"requirements"` // Unmet requirements, empty when unlocked
+    Owned        bool     `json:"owned"`        // True if the player already has this item
+}
+
+// **Build Shop Catalog**
+// Lists the shop stock for a player with lock state. Caller must hold `mu`.
+func buildShopCatalog(player *Player) []ShopListing {
+    listings := make([]ShopListing, 0, len(shopStock))
+    for _, id := range shopStock {
+        entry := itemCatalog[id]
+        unmet := unmetRequirements(player, id)
+        listings = append(listings, ShopListing{
+            CatalogItem:  entry,
+            Locked:       len(unmet) > 0,
+            Requirements: unmet,
+            Owned:        hasItem(player, id),
+        })
+    }
+    return listings
+}
+
+// **Handle Shop Catalog**
+// Replies to a `shopCatalog` request with the items the player can see.
+func handleShopCatalog(req Request) {
+    player := req.Player
+    mu.Lock()
+    defer mu.Unlock()
+
+    catalogMessage := Message{
+        Type:      "shopCatalog",
+        RequestID: req.RequestID,
+        Data: ShopCatalogData{
+            Items: buildShopCatalog(player),
+        },
+    }
+    if err := player.Send(catalogMessage); err != nil {
+        ErrorLogger.Printf("Error sending shop catalog to player %s: %v", player.ID, err)
+    }
+}
+
+// **Insufficient Funds Error**
+// Returned when a player can't afford a purchase.
+type InsufficientFundsError struct {
+    Required int
+    Balance  int
+}
+
+func (e *InsufficientFundsError) Error() string {
+    return fmt.Sprintf("insufficient funds: need %d, have %d", e.Required, e.Balance)
+}
+
+// **Find Shop Item**
+// Resolves a purchase request against the shop stock.
+func findShopItem(itemID string) (CatalogItem, error) {
+    entry, err := lookupCatalogItem(itemID, "")
+    if err != nil {
+        return CatalogItem{}, err
+    }
+    for _, id := range shopStock {
+        if id == entry.ID {
+            return entry, nil
+        }
+    }
+    return CatalogItem{}, fmt.Errorf("%s is not for sale", entry.Name)
+}
+
+// **Has Item**
+// Reports whether the player's inventory contains the catalog item. Caller must hold `mu`.
+func hasItem(player *Player, id string) bool {
+    for _, invItem := range player.Inventory {
+        if invItem.ID == id {
+            return true
+        }
+    }
+    return false
+}
+
+// **Handle Buy Item**
+// Debits the player's balance and grants the purchased item.
+func handleBuyItem(req Request, buy *BuyItemRequest) {
+    player := req.Player
+    DebugLogger.Printf("Entered handleBuyItem for player %s with item: %+v\n", player.ID, buy)
+
+    mu.Lock()
+    defer mu.Unlock()
+
+    if replayTradeLocked(req) {
+        return
+    }
+
+    entry, err := findShopItem(buy.ItemID)
+    if err != nil {
+        WarningLogger.Printf("Invalid purchase by player %s: %v", player.ID, err)
+        sendError(player, req.RequestID, ErrCodeNotForSale, err.Error())
+        return
+    }
+
+    // Rods are tools, owning one is enough
+    quantity := buy.Quantity
+    if entry.Type == "Pole" {
+        quantity = 1
+    }
+
+    if entry.Type == "Pole" && hasItem(player, entry.ID) {
+        sendError(player, req.RequestID, ErrCodeAlreadyOwned, fmt.Sprintf("you already own %s", entry.Name))
+        return
+    }
+
+    if unmet := unmetRequirements(player, entry.ID); len(unmet) > 0 {
+        sendError(player, req.RequestID, ErrCodeLocked, fmt.Sprintf("%s is locked: %s", entry.Name, strings.Join(unmet, ", ")))
+        return
+    }
+
+    price, err := buyItem(player, entry, quantity)
+    var fundsErr *InsufficientFundsError
+    switch {
+    case errors.As(err, &fundsErr):
+        WarningLogger.Printf("Purchase of %s by player %s rejected: %v", entry.Name, player.ID, err)
+        sendError(player, req.RequestID, ErrCodeInsufficientFunds, err.Error())
+        return
+    case errors.Is(err, ErrInventoryFull):
+        sendError(player, req.RequestID, ErrCodeInventoryFull, "Your inventory is full")
+        return
+    case err != nil:
+        sendError(player, req.RequestID, ErrCodeTradeFailed, err.Error())
+        return
+    }
+
+    DebugLogger.Printf("Player %s bought %d x %s for %d. New balance: %d", player.ID, quantity, entry.Name, price, player.Balance)
+
+    buyMessage := Message{
+        Type:   "buyEvent",
+        Player: player,
+        Data: BuyEventData{
+            Event:    "itemBought",
+            PlayerID: player.ID,
+            Item:     entry.toItem(quantity),
+            Price:    price,
+        },
+    }
+    rememberReplyLocked(req, buyMessage)
+    sendTradeResultLocked(req, buyMessage)
+}
@@ -0,0 +1,98 @@
+package main
+
+// **Grid Cell**
+// A square of the map `config.World.ViewRadius` tiles wide, so everything
+// within view of a tile is in its cell or the ones around it.
+type gridCell struct {
+    X, Y int
+}
+
+// **Spatial Index**
+// Players by grid cell, and the cell each player was last filed under. Guarded by `mu`.
+var (
+    gridCells   = make(map[gridCell]map[string]*Player)
+    playerCells = make(map[string]gridCell)
+)
+
+// **Cell Of**
+// The grid cell containing the tile.
+func cellOf(x, y int) gridCell {
+    size := config.World.ViewRadius
+    return gridCell{X: floorDiv(x, size), Y: floorDiv(y, size)}
+}
+
+// **Floor Div**
+// Integer division rounding down, so tiles left of or above the map still land in their own cells.
+func floorDiv(a, b int) int {
+    q := a / b
+    if a%b != 0 && (a < 0) != (b < 0) {
+        q--
+    }
+    return q
+}
+
+// **Index Player (Locked)**
+// Files the player under the cell of their current position. Call whenever
+// a player joins or moves. Caller must hold `mu`.
+func indexPlayerLocked(player *Player) {
+    cell := cellOf(player.X, player.Y)
+    if old, ok := playerCells[player.ID]; ok {
+        if old == cell {
+            return
+        }
+        unindexPlayerLocked(player.ID)
+    }
+    members, ok := gridCells[cell]
+    if !ok {
+        members = make(map[string]*Player)
+        gridCells[cell] = members
+    }
+    members[player.ID] = player
+    playerCells[player.ID] = cell
+}
+
+// **Unindex Player (Locked)**
+// Removes a player who left the game from the index. Caller must hold `mu`.
+func unindexPlayerLocked(playerID string) {
+    cell, ok := playerCells[playerID]
+    if !ok {
+        return
+    }
+    delete(gridCells[cell], playerID)
+    if len(gridCells[cell]) == 0 {
+        delete(gridCells, cell)
+    }
+    delete(playerCells, playerID)
+}
+
+// **In View**
+// Whether a tile is within the view radius of another, counting diagonal
+// steps as one like the client's sight range does.
+func inView(x1, y1, x2, y2 int) bool {
+    dx, dy := x1-x2, y1-y2
+    if dx < 0 {
+        dx = -dx
+    }
+    if dy < 0 {
+        dy = -dy
+    }
+    radius := config.World.ViewRadius
+    return dx <= radius && dy <= radius
+}
+
+// **Players In View (Locked)**
+// The players the viewer can see, including themselves. Caller must hold `mu`.
+func playersInViewLocked(viewer *Player) []*Player {
+    center := cellOf(viewer.X, viewer.Y)
+    visible := []*Player{}
+    for cy := center.Y - 1; cy <= center.Y+1; cy++ {
+        for cx := center.X - 1; cx <= center.X+1; cx++ {
+            for _, p := range gridCells[gridCell{X: cx, Y: cy}] {
+                if inView(viewer.X, viewer.Y, p.X, p.Y) {
+                    visible = append(visible, p)
+                }
+            }
+        }
+    }
+    return visible
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+)
+
+// **Player Not Found Error**
+// Returned by `PlayerStore.LoadPlayer` when the player has never been saved.
+var ErrPlayerNotFound = errors.New("player not found")
+
+// **Player Store Interface**
+// Persists player state and inventories. Implementations live in store_sql.go
+// (MySQL and SQLite) and store_memory.go.
+type PlayerStore interface {
+    // LoadPlayer returns the saved player with their inventory, or ErrPlayerNotFound.
+    LoadPlayer(playerID string) (*Player, error)
+    // SavePlayer writes the player's full state, replacing their saved inventory.
+    SavePlayer(player *Player) error
+    // SaveItem inserts or updates a single inventory item by UID at its inventory slot,
+    // or returns ErrPlayerNotFound if the player was never saved.
+    SaveItem(playerID string, item Item, slot int) error
+    // ApplyTrade writes the player's row and the changed inventory rows in one transaction.
+    ApplyTrade(trade Trade) error
+    // CreateAccount stores a new account, or returns ErrAccountExists.
+    CreateAccount(playerID string, passwordHash string) error
+    // LoadAccount returns the account's password hash, or ErrAccountNotFound.
+    LoadAccount(playerID string) (string, error)
+    // Close releases any resources held by the store.
+    Close() error
+}
+
+// **Store**
+// The player store selected at startup.
+var store PlayerStore
+
+// **Open Player Store**
+// Creates the store for the given driver: "mysql", "sqlite" or "memory".
+func openPlayerStore(driver, dsn string) (PlayerStore, error) {
+    switch driver {
+    case "mysql":
+        return openSQLStore(mysqlDialect, dsn)
+    case "sqlite":
+        return openSQLStore(sqliteDialect, dsn)
+    case "memory":
+        return newMemoryStore(), nil
+    default:
+        return nil, fmt.Errorf("unknown store driver %q", driver)
+    }
+}
+
+// **Copy Player**
+// Returns a copy of the persisted fields of a player, without the connection.
+func copyPlayer(player *Player) *Player {
+    inventory := make([]Item, len(player.Inventory))
+    copy(inventory, player.Inventory)
+    var records map[string]Catch
+    if player.Records != nil {
+        records = make(map[string]Catch, len(player.Records))
+        for id, catch := range player.Records {
+            records[id] = catch
+        }
+    }
+    return &Player{
+        ID:          player.ID,
+        X:           player.X,
+        Y:           player.Y,
+        Direction:   player.Direction,
+        FacingWater: player.FacingWater,
+        Inventory:   inventory,
+        Balance:     player.Balance,
+        EquippedRod: player.EquippedRod,
+        Records:     records,
+    }
+}
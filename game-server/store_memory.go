@@ -0,0 +1,110 @@
+package main
+
+import (
+    "sync"
+)
+
+// **Memory Store**
+// Keeps players in process memory. Nothing survives a restart, which makes it
+// handy for local development and tests.
+type memoryStore struct {
+    mu       sync.Mutex
+    players  map[string]*Player
+    accounts map[string]string // Password hashes by player ID
+}
+
+// **New Memory Store**
+// Creates an empty in-memory store.
+func newMemoryStore() *memoryStore {
+    return &memoryStore{players: make(map[string]*Player), accounts: make(map[string]string)}
+}
+
+func (s *memoryStore) LoadPlayer(playerID string) (*Player, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    saved, ok := s.players[playerID]
+    if !ok {
+        return nil, ErrPlayerNotFound
+    }
+    return copyPlayer(saved), nil
+}
+
+func (s *memoryStore) SavePlayer(player *Player) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.players[player.ID] = copyPlayer(player)
+    return nil
+}
+
+func (s *memoryStore) SaveItem(playerID string, item Item, slot int) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    saved, ok := s.players[playerID]
+    if !ok {
+        return ErrPlayerNotFound
+    }
+    saved.Inventory = replaceItemRow(saved.Inventory, item)
+    return nil
+}
+
+func (s *memoryStore) ApplyTrade(trade Trade) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    saved := copyPlayer(trade.Player)
+    if existing, ok := s.players[saved.ID]; ok {
+        saved.Inventory = existing.Inventory
+    }
+    for _, row := range trade.Changed {
+        saved.Inventory = replaceItemRow(saved.Inventory, row)
+    }
+    s.players[saved.ID] = saved
+    return nil
+}
+
+// **Replace Item Row**
+// Updates, adds or (for a quantity of 0) removes an item row by UID.
+func replaceItemRow(inventory []Item, row Item) []Item {
+    for i, invItem := range inventory {
+        if invItem.UID == row.UID {
+            if row.Quantity <= 0 {
+                return append(inventory[:i], inventory[i+1:]...)
+            }
+            inventory[i] = row
+            return inventory
+        }
+    }
+    if row.Quantity <= 0 {
+        return inventory
+    }
+    return append(inventory, row)
+}
+
+func (s *memoryStore) CreateAccount(playerID string, passwordHash string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if _, ok := s.accounts[playerID]; ok {
+        return ErrAccountExists
+    }
+    s.accounts[playerID] = passwordHash
+    return nil
+}
+
+func (s *memoryStore) LoadAccount(playerID string) (string, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    hash, ok := s.accounts[playerID]
+    if !ok {
+        return "", ErrAccountNotFound
+    }
+    return hash, nil
+}
+
+func (s *memoryStore) Close() error {
+    return nil
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "time"
+
+    _ "github.com/go-sql-driver/mysql"
+    _ "github.com/mattn/go-sqlite3"
+)
+
+// **SQL Dialect**
+// The driver name and statements that differ between SQL databases.
+type sqlDialect struct {
+    name         string   // database/sql driver name
+    upsertPlayer string   // Insert or update a players row
+    upsertItem   string   // Insert or update an items row
+    upsertRecord string   // Insert or update a catch_records row
+    migrationsDir string  // Directory under migrations/ holding this dialect's schema
+}
+
+// **MySQL Dialect**
+var mysqlDialect = sqlDialect{
+    name: "mysql",
+    migrationsDir: "mysql",
+    upsertPlayer: `
+        INSERT INTO players (player_id, x, y, direction, facing_water, balance, equipped_rod)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+        ON DUPLICATE KEY UPDATE x = VALUES(x), y = VALUES(y), direction = VALUES(direction),
+                                facing_water = VALUES(facing_water), balance = VALUES(balance),
+                                equipped_rod = VALUES(equipped_rod)
+    `,
+    upsertItem: `
+        INSERT INTO items (item_uid, player_id, item_name, quantity, value, img, type, attrs, slot, acquired_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        ON DUPLICATE KEY UPDATE quantity = VALUES(quantity), value = VALUES(value), img = VALUES(img), type = VALUES(type),
+                                attrs = VALUES(attrs), slot = VALUES(slot)
+    `,
+    upsertRecord: `
+        INSERT INTO catch_records (player_id, fish_id, length, weight, quality, caught_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+        ON DUPLICATE KEY UPDATE length = VALUES(length), weight = VALUES(weight), quality = VALUES(quality),
+                                caught_at = VALUES(caught_at)
+    `,
+}
+
+// **SQLite Dialect**
+var sqliteDialect = sqlDialect{
+    name: "sqlite3",
+    upsertPlayer: `
+        INSERT INTO players (player_id, x, y, direction, facing_water, balance, equipped_rod)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT(player_id) DO UPDATE SET x = excluded.x, y = excluded.y, direction = excluded.direction,
+                                             facing_water = excluded.facing_water, balance = excluded.balance,
+                                             equipped_rod = excluded.equipped_rod
+    `,
+    upsertItem: `
+        INSERT INTO items (item_uid, player_id, item_name, quantity, value, img, type, attrs, slot, acquired_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT(item_uid) DO UPDATE SET quantity = excluded.quantity, value = excluded.value, img = excluded.img,
+                                            type = excluded.type, attrs = excluded.attrs, slot = excluded.slot
+    `,
+    upsertRecord: `
+        INSERT INTO catch_records (player_id, fish_id, length, weight, quality, caught_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+        ON CONFLICT(player_id, fish_id) DO UPDATE SET length = excluded.length, weight = excluded.weight,
+                                                      quality = excluded.quality, caught_at = excluded.caught_at
+    `,
+    migrationsDir: "sqlite",
+}
+
+// **SQL Store**
+// A `PlayerStore` backed by a database/sql connection pool.
+type sqlStore struct {
+    db      *sql.DB
+    dialect sqlDialect
+}
+
+// **Open SQL DB**
+// Connects to the database and checks the connection.
+func openSQLDB(dialect sqlDialect, dsn string) (*sql.DB, error) {
+    db, err := sql.Open(dialect.name, dsn)
+    if err != nil {
+        return nil, fmt.Errorf("error connecting to database: %v", err)
+    }
+    if err := db.Ping(); err != nil {
+        db.Close()
+        return nil, fmt.Errorf("database connection failed: %v", err)
+    }
+    return db, nil
+}
+
+// **Open SQL Store**
+// Connects to the database and brings its schema up to date.
+func openSQLStore(dialect sqlDialect, dsn string) (*sqlStore, error) {
+    db, err := openSQLDB(dialect, dsn)
+    if err != nil {
+        return nil, err
+    }
+    count, err := migrateUp(db, dialect)
+    if err != nil {
+        db.Close()
+        return nil, fmt.Errorf("failed to migrate database: %v", err)
+    }
+    if count > 0 {
+        InfoLogger.Printf("Applied %d database migration(s)", count)
+    }
+    return &sqlStore{db: db, dialect: dialect}, nil
+}
+
+func (s *sqlStore) LoadPlayer(playerID string) (*Player, error) {
+    player := &Player{
+        ID: playerID,
+        Inventory: []Item{}, // Initialize inventory
+    }
+
+    // Load player state
+    query := `SELECT x, y, direction, facing_water, balance, IFNULL(equipped_rod, '') FROM players WHERE player_id = ?`
+    row := s.db.QueryRow(query, playerID)
+    if err := row.Scan(&player.X, &player.Y, &player.Direction, &player.FacingWater, &player.Balance, &player.EquippedRod); err != nil {
+        if errors.Is(err, sql.ErrNoRows) {
+            return nil, ErrPlayerNotFound
+        }
+        return nil, fmt.Errorf("failed to load player state: %v", err)
+    }
+
+    // Load inventory in slot order
+    invQuery := `SELECT item_uid, item_name, quantity, value, img, IFNULL(type, 'Unknown'), IFNULL(attrs, ''), acquired_at
+                 FROM items WHERE player_id = ? ORDER BY slot, acquired_at`
+    rows, err := s.db.Query(invQuery, playerID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load inventory: %v", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var item Item
+        var attrs string
+        if err := rows.Scan(&item.UID, &item.Name, &item.Quantity, &item.Value, &item.Img, &item.Type, &attrs, &item.AcquiredAt); err != nil {
+            return nil, fmt.Errorf("failed to scan inventory item: %v", err)
+        }
+        if err := decodeItemAttrs(&item, attrs); err != nil {
+            return nil, fmt.Errorf("failed to decode attributes of %s: %v", item.Name, err)
+        }
+        player.Inventory = append(player.Inventory, item)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("failed to load inventory: %v", err)
+    }
+
+    // Load personal records
+    recordRows, err := s.db.Query(`SELECT fish_id, length, weight, quality, caught_at FROM catch_records WHERE player_id = ?`, playerID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load catch records: %v", err)
+    }
+    defer recordRows.Close()
+
+    for recordRows.Next() {
+        var fishID string
+        var record Catch
+        if err := recordRows.Scan(&fishID, &record.Length, &record.Weight, &record.Quality, &record.CaughtAt); err != nil {
+            return nil, fmt.Errorf("failed to scan catch record: %v", err)
+        }
+        if player.Records == nil {
+            player.Records = make(map[string]Catch)
+        }
+        player.Records[fishID] = record
+    }
+    if err := recordRows.Err(); err != nil {
+        return nil, fmt.Errorf("failed to load catch records: %v", err)
+    }
+
+    return player, nil
+}
+
+func (s *sqlStore) SavePlayer(player *Player) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return fmt.Errorf("failed to save player state: %v", err)
+    }
+    defer tx.Rollback()
+
+    _, err = tx.Exec(s.dialect.upsertPlayer, player.ID, player.X, player.Y, player.Direction, player.FacingWater, player.Balance, player.EquippedRod)
+    if err != nil {
+        return fmt.Errorf("failed to save player state: %v", err)
+    }
+
+    // Replace the saved inventory so sold items don't linger
+    if _, err := tx.Exec(`DELETE FROM items WHERE player_id = ?`, player.ID); err != nil {
+        return fmt.Errorf("failed to save inventory for player %s: %v", player.ID, err)
+    }
+    for slot, item := range player.Inventory {
+        if err := s.upsertItem(tx, player.ID, item, slot); err != nil {
+            return fmt.Errorf("failed to save inventory for player %s: %v", player.ID, err)
+        }
+    }
+    for fishID, record := range player.Records {
+        _, err := tx.Exec(s.dialect.upsertRecord, player.ID, fishID, record.Length, record.Weight, record.Quality, record.CaughtAt)
+        if err != nil {
+            return fmt.Errorf("failed to save catch records for player %s: %v", player.ID, err)
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("failed to save player state: %v", err)
+    }
+    return nil
+}
+
+func (s *sqlStore) SaveItem(playerID string, item Item, slot int) error {
+    // The schema has no foreign key to refuse an item without its player
+    var exists int
+    err := s.db.QueryRow(`SELECT 1 FROM players WHERE player_id = ?`, playerID).Scan(&exists)
+    if errors.Is(err, sql.ErrNoRows) {
+        return ErrPlayerNotFound
+    }
+    if err != nil {
+        return fmt.Errorf("failed to save item %s: %v", item.Name, err)
+    }
+    if err := s.upsertItem(s.db, playerID, item, slot); err != nil {
+        return fmt.Errorf("failed to save item %s: %v", item.Name, err)
+    }
+    return nil
+}
+
+func (s *sqlStore) ApplyTrade(trade Trade) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return fmt.Errorf("failed to start trade: %v", err)
+    }
+    defer tx.Rollback()
+
+    player := trade.Player
+    _, err = tx.Exec(s.dialect.upsertPlayer, player.ID, player.X, player.Y, player.Direction, player.FacingWater, player.Balance, player.EquippedRod)
+    if err != nil {
+        return fmt.Errorf("failed to update balance: %v", err)
+    }
+
+    for _, row := range trade.Changed {
+        if row.Quantity <= 0 {
+            _, err = tx.Exec(`DELETE FROM items WHERE player_id = ? AND item_uid = ?`, player.ID, row.UID)
+        } else {
+            err = s.upsertItem(tx, player.ID, row, findItem(player.Inventory, row.UID))
+        }
+        if err != nil {
+            return fmt.Errorf("failed to update item %s: %v", row.Name, err)
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("failed to commit trade: %v", err)
+    }
+    return nil
+}
+
+// **SQL Executor**
+// What `upsertItem` needs from a `*sql.DB` or `*sql.Tx`.
+type sqlExecutor interface {
+    Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// **Item Attributes**
+// What makes an item unique, stored as JSON in the `attrs` column.
+type itemAttrs struct {
+    Catch   *Catch    `json:"catch,omitempty"`
+    Rod     *RodState `json:"rod,omitempty"`
+    Catches []Catch   `json:"catches,omitempty"` // A fish stack's catches from before item instances, see splitLegacyCatches
+}
+
+// **Upsert Item**
+// Writes an items row at the given inventory slot.
+func (s *sqlStore) upsertItem(exec sqlExecutor, playerID string, item Item, slot int) error {
+    var attrs sql.NullString
+    if item.Catch != nil || item.Rod != nil {
+        encoded, err := json.Marshal(itemAttrs{Catch: item.Catch, Rod: item.Rod})
+        if err != nil {
+            return err
+        }
+        attrs = sql.NullString{String: string(encoded), Valid: true}
+    }
+    _, err := exec.Exec(s.dialect.upsertItem, item.UID, playerID, item.Name, item.Quantity, item.Value, item.Img, item.Type,
+        attrs, slot, item.AcquiredAt)
+    return err
+}
+
+// **Decode Item Attributes**
+// Fills in the item's attributes from its `attrs` column.
+func decodeItemAttrs(item *Item, attrs string) error {
+    if attrs == "" {
+        return nil
+    }
+    var decoded itemAttrs
+    if err := json.Unmarshal([]byte(attrs), &decoded); err != nil {
+        return err
+    }
+    item.Catch = decoded.Catch
+    item.Rod = decoded.Rod
+    return nil
+}
+
+// **Split Legacy Catches**
+// Migration 0006. Rewrites every fish stack that came over from the old
+// inventory table with its catches as a unique item per catch, plus a stack
+// of the fish that had none, keeping the stack's slot.
+func splitLegacyCatches(tx *sql.Tx, dialect sqlDialect) error {
+    rows, err := tx.Query(`SELECT item_uid, player_id, item_name, quantity, value, img, IFNULL(type, 'Unknown'), attrs, slot
+                           FROM items WHERE attrs LIKE '%"catches"%'`)
+    if err != nil {
+        return fmt.Errorf("failed to find catch stacks: %v", err)
+    }
+    type catchStack struct {
+        playerID string
+        slot     int
+        item     Item
+        catches  []Catch
+    }
+    var stacks []catchStack
+    for rows.Next() {
+        var stack catchStack
+        var attrs string
+        if err := rows.Scan(&stack.item.UID, &stack.playerID, &stack.item.Name, &stack.item.Quantity, &stack.item.Value,
+            &stack.item.Img, &stack.item.Type, &attrs, &stack.slot); err != nil {
+            rows.Close()
+            return fmt.Errorf("failed to scan catch stack: %v", err)
+        }
+        var decoded itemAttrs
+        if err := json.Unmarshal([]byte(attrs), &decoded); err != nil {
+            rows.Close()
+            return fmt.Errorf("failed to decode attributes of %s: %v", stack.item.UID, err)
+        }
+        stack.catches = decoded.Catches
+        stacks = append(stacks, stack)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return fmt.Errorf("failed to find catch stacks: %v", err)
+    }
+
+    // Rows must be closed before writing, SQLite runs the transaction on one connection
+    s := &sqlStore{dialect: dialect}
+    for _, stack := range stacks {
+        if _, err := tx.Exec(`DELETE FROM items WHERE item_uid = ?`, stack.item.UID); err != nil {
+            return fmt.Errorf("failed to remove catch stack %s: %v", stack.item.UID, err)
+        }
+        for _, item := range splitCatchStack(stack.item, stack.catches) {
+            if err := s.upsertItem(tx, stack.playerID, item, stack.slot); err != nil {
+                return fmt.Errorf("failed to save catches of %s: %v", stack.item.UID, err)
+            }
+        }
+    }
+    return nil
+}
+
+// **Split Catch Stack**
+// A stack of `item` as an item per catch, plus the fish without one still
+// stacked. Catches past the stack's quantity are the oldest and are dropped.
+func splitCatchStack(item Item, catches []Catch) []Item {
+    if extra := len(catches) - item.Quantity; extra > 0 {
+        catches = catches[extra:]
+    }
+    split := make([]Item, 0, len(catches)+1)
+    if untracked := item.Quantity - len(catches); untracked > 0 {
+        item.Quantity = untracked
+        split = append(split, item)
+    }
+    for i := range catches {
+        catch := catches[i]
+        split = append(split, Item{
+            UID:        newItemUID(),
+            Type:       item.Type,
+            Name:       item.Name,
+            Quantity:   1,
+            Value:      item.Value,
+            Img:        item.Img,
+            Catch:      &catch,
+            AcquiredAt: catch.CaughtAt,
+        })
+    }
+    return split
+}
+
+func (s *sqlStore) CreateAccount(playerID string, passwordHash string) error {
+    _, err := s.db.Exec(`INSERT INTO accounts (player_id, password_hash, created_at) VALUES (?, ?, ?)`,
+        playerID, passwordHash, time.Now().UTC().Format(time.RFC3339))
+    if err != nil {
+        // Tell a duplicate apart from a real failure without parsing driver errors
+        if _, loadErr := s.LoadAccount(playerID); loadErr == nil {
+            return ErrAccountExists
+        }
+        return fmt.Errorf("failed to create account: %v", err)
+    }
+    return nil
+}
+
+func (s *sqlStore) LoadAccount(playerID string) (string, error) {
+    var hash string
+    err := s.db.QueryRow(`SELECT password_hash FROM accounts WHERE player_id = ?`, playerID).Scan(&hash)
+    if errors.Is(err, sql.ErrNoRows) {
+        return "", ErrAccountNotFound
+    }
+    if err != nil {
+        return "", fmt.Errorf("failed to load account: %v", err)
+    }
+    return hash, nil
+}
+
+func (s *sqlStore) Close() error {
+    return s.db.Close()
+}
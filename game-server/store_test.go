@@ -0,0 +1,173 @@
+package main
+
+import (
+    "errors"
+    "testing"
+)
+
+// **Test Stores**
+// Constructors for every store the economy tests run against.
+var testStores = map[string]func(t *testing.T) PlayerStore{
+    "memory": func(t *testing.T) PlayerStore { return newMemoryStore() },
+    "sqlite": func(t *testing.T) PlayerStore { return openTestSQLite(t) },
+}
+
+// **Open Test SQLite**
+// A migrated SQLite store in memory. Every connection to `:memory:` is a
+// database of its own, so the pool is held to one.
+func openTestSQLite(t *testing.T) *sqlStore {
+    db, err := openSQLDB(sqliteDialect, ":memory:")
+    if err != nil {
+        t.Fatalf("open sqlite: %v", err)
+    }
+    db.SetMaxOpenConns(1)
+    t.Cleanup(func() { db.Close() })
+    if _, err := migrateUp(db, sqliteDialect); err != nil {
+        t.Fatalf("migrate sqlite: %v", err)
+    }
+    return &sqlStore{db: db, dialect: sqliteDialect}
+}
+
+// **Use Store**
+// Makes `s` the global store for the rest of the test.
+func useStore(t *testing.T, s PlayerStore) {
+    previous := store
+    store = s
+    t.Cleanup(func() { store = previous })
+}
+
+// **Failing Store**
+// A store whose trades always fail, for checking nothing changes when they do.
+type failingStore struct {
+    PlayerStore
+}
+
+var errStoreDown = errors.New("store is down")
+
+func (s failingStore) ApplyTrade(trade Trade) error {
+    return errStoreDown
+}
+
+// **Saved Test Player**
+// A player with a stack of Commonfish and a Half Decent Rod in hand, saved to the global store.
+func savedTestPlayer(t *testing.T, balance int) *Player {
+    rod := itemCatalog["rod-half-decent"].toItem(1)
+    rod.UID = "rod-1"
+    fish := itemCatalog["commonfish"].toItem(12)
+    fish.UID = "fish-1"
+    player := &Player{
+        ID:          "trader",
+        Direction:   "down",
+        Inventory:   []Item{rod, fish},
+        Balance:     balance,
+        EquippedRod: rod.ID,
+    }
+    if err := store.SavePlayer(player); err != nil {
+        t.Fatalf("save player: %v", err)
+    }
+    return player
+}
+
+// **Load Test Player**
+// The player as the global store has them saved, normalized like a joining player.
+func loadTestPlayer(t *testing.T, playerID string) *Player {
+    saved, err := store.LoadPlayer(playerID)
+    if err != nil {
+        t.Fatalf("load player: %v", err)
+    }
+    saved.Inventory = normalizeInventory(playerID, saved.Inventory)
+    return saved
+}
+
+// **Quantity Of**
+// How many items with the catalog ID the player has.
+func quantityOf(player *Player, id string) int {
+    total := 0
+    for _, invItem := range player.Inventory {
+        if invItem.ID == id {
+            total += invItem.Quantity
+        }
+    }
+    return total
+}
+
+func TestLoadPlayerNotFound(t *testing.T) {
+    for name, open := range testStores {
+        if _, err := open(t).LoadPlayer("nobody"); !errors.Is(err, ErrPlayerNotFound) {
+            t.Errorf("%s: got %v, want ErrPlayerNotFound", name, err)
+        }
+    }
+}
+
+// Saving an item must not make up a player that was never saved.
+func TestSaveItemUnknownPlayer(t *testing.T) {
+    for name, open := range testStores {
+        s := open(t)
+        fish := itemCatalog["commonfish"].toItem(1)
+        fish.UID = "fish-1"
+        if err := s.SaveItem("nobody", fish, 0); !errors.Is(err, ErrPlayerNotFound) {
+            t.Errorf("%s: SaveItem got %v, want ErrPlayerNotFound", name, err)
+        }
+        if _, err := s.LoadPlayer("nobody"); !errors.Is(err, ErrPlayerNotFound) {
+            t.Errorf("%s: LoadPlayer after SaveItem got %v, want ErrPlayerNotFound", name, err)
+        }
+    }
+}
+
+// A failure half way through a trade must leave the saved player as it was.
+func TestSQLTradeRollsBack(t *testing.T) {
+    useStore(t, openTestSQLite(t))
+    player := savedTestPlayer(t, 100)
+    sqlite := store.(*sqlStore)
+    if _, err := sqlite.db.Exec(`DROP TABLE items`); err != nil {
+        t.Fatalf("drop items: %v", err)
+    }
+
+    after := copyPlayer(player)
+    after.Balance = 5000
+    after.Inventory[1].Quantity = 1
+    err := sqlite.ApplyTrade(Trade{Player: after, Changed: []Item{after.Inventory[1]}})
+    if err == nil {
+        t.Fatal("trade succeeded without an items table")
+    }
+    var balance int
+    if err := sqlite.db.QueryRow(`SELECT balance FROM players WHERE player_id = ?`, player.ID).Scan(&balance); err != nil {
+        t.Fatalf("read balance: %v", err)
+    }
+    if balance != 100 {
+        t.Errorf("balance after the failed trade = %d, want 100", balance)
+    }
+}
+
+// A snapshot saved after a newer trade must not put the old balance back.
+func TestStaleSnapshotKeepsTrade(t *testing.T) {
+    for storeName, open := range testStores {
+        t.Run(storeName, func(t *testing.T) {
+            useStore(t, open(t))
+            player := savedTestPlayer(t, 100)
+            mu.Lock()
+            players[player.ID] = player
+            mu.Unlock()
+            t.Cleanup(func() {
+                mu.Lock()
+                delete(players, player.ID)
+                mu.Unlock()
+            })
+
+            mu.Lock()
+            stale := snapshotPlayerLocked(player)
+            _, _, err := sellItem(player, itemCatalog["commonfish"], "", 5)
+            mu.Unlock()
+            if err != nil {
+                t.Fatalf("sellItem error = %v", err)
+            }
+            if err := saveSnapshot(stale); err != nil {
+                t.Fatalf("saveSnapshot error = %v", err)
+            }
+            saved := loadTestPlayer(t, player.ID)
+            if saved.Balance != player.Balance || quantityOf(saved, "commonfish") != 7 {
+                t.Errorf("saved balance %d with %d Commonfish, want %d with 7", saved.Balance, quantityOf(saved, "commonfish"), player.Balance)
+            }
+        })
+    }
+}
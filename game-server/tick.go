@@ -0,0 +1,154 @@
+package main
+
+import (
+    "container/heap"
+    "time"
+)
+
+// **Input Queue**
+// Requests from players waiting to be processed by the game loop, in arrival order.
+var inputQueue = make(chan Request, 1024)
+
+// **Departed Players**
+// Players taken out of the game whose game loop state, like a cast still in
+// the water, hasn't been cleared yet. Guarded by `mu`.
+var departedPlayers []*Player
+
+// **Current Tick**
+// Number of ticks the game loop has run. Only touched by the game loop.
+var currentTick uint64
+
+// **Tick System**
+// Runs once per tick after inputs and timers, e.g. automation machines or bank interest.
+type TickSystem func(tick uint64, now time.Time)
+
+// **Tick Systems**
+// Systems advanced by the game loop, in registration order.
+var tickSystems []TickSystem
+
+// **Register Tick System**
+// Adds a system to the game loop. Call before the loop starts.
+func registerTickSystem(system TickSystem) {
+    tickSystems = append(tickSystems, system)
+}
+
+// **Run Game Loop**
+// Advances the simulation at a fixed rate. All game state changes happen on this goroutine.
+func runGameLoop(tickRate int) {
+    DebugLogger.Printf("Game loop started at %d ticks per second", tickRate)
+    ticker := time.NewTicker(time.Second / time.Duration(tickRate))
+    defer ticker.Stop()
+    for now := range ticker.C {
+        runTick(now)
+    }
+}
+
+// **Run Tick**
+// Processes one simulation step: queued inputs, due timers, systems, then the state snapshot.
+func runTick(now time.Time) {
+    currentTick++
+    drainInputs()
+    clearDeparted()
+    runDueTimers(now)
+    for _, system := range tickSystems {
+        system(currentTick, now)
+    }
+    flushSnapshot()
+}
+
+// **Drain Inputs**
+// Handles the messages queued since the last tick. Anything arriving while
+// draining waits for the next tick, so a flood of input can't stall the loop.
+func drainInputs() {
+    pending := len(inputQueue)
+    for i := 0; i < pending; i++ {
+        handleRequest(<-inputQueue)
+    }
+}
+
+// **Clear Departed**
+// Ends the casts of players who left since the last tick, before their timers
+// can land a fish on a player that's no longer in the game.
+func clearDeparted() {
+    mu.Lock()
+    departed := departedPlayers
+    departedPlayers = nil
+    mu.Unlock()
+    for _, player := range departed {
+        abandonFishing(player)
+    }
+}
+
+// **Timer Structure**
+// A callback scheduled to run on the game loop.
+type timer struct {
+    at    time.Time
+    seq   uint64 // Keeps timers due at the same time in scheduling order
+    fn    func()
+    index int
+}
+
+// **Cancel**
+// Stops the timer from firing. Safe to call on a timer that already ran.
+func (t *timer) cancel() {
+    if t != nil {
+        t.fn = nil
+    }
+}
+
+// **Timer Heap**
+// Pending timers ordered by due time.
+type timerHeap []*timer
+
+func (h timerHeap) Len() int { return len(h) }
+func (h timerHeap) Less(i, j int) bool {
+    if h[i].at.Equal(h[j].at) {
+        return h[i].seq < h[j].seq
+    }
+    return h[i].at.Before(h[j].at)
+}
+func (h timerHeap) Swap(i, j int) {
+    h[i], h[j] = h[j], h[i]
+    h[i].index = i
+    h[j].index = j
+}
+func (h *timerHeap) Push(x interface{}) {
+    t := x.(*timer)
+    t.index = len(*h)
+    *h = append(*h, t)
+}
+func (h *timerHeap) Pop() interface{} {
+    old := *h
+    t := old[len(old)-1]
+    *h = old[:len(old)-1]
+    return t
+}
+
+// **Timers**
+// Pending timers and the scheduling counter. Only touched by the game loop.
+var (
+    timers   timerHeap
+    timerSeq uint64
+)
+
+// **Schedule**
+// Runs `fn` on the game loop once `delay` has passed. Only call from the game loop.
+func schedule(delay time.Duration, fn func()) *timer {
+    timerSeq++
+    t := &timer{at: time.Now().Add(delay), seq: timerSeq, fn: fn}
+    heap.Push(&timers, t)
+    return t
+}
+
+// **Run Due Timers**
+// Fires every timer due at or before `now`, including ones scheduled by other timers.
+func runDueTimers(now time.Time) {
+    for timers.Len() > 0 && !timers[0].at.After(now) {
+        t := heap.Pop(&timers).(*timer)
+        if t.fn != nil {
+            fn := t.fn
+            t.fn = nil
+            fn()
+        }
+    }
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "strings"
+)
+
+// **Rod Repair Rate**
+// Share of a rod's catalog value that restoring it from broken to new costs.
+const rodRepairRate = 0.5
+
+// **Rod Maxed Error**
+// Returned when the stat is already upgraded as far as it goes.
+var ErrRodMaxed = errors.New("that stat is fully upgraded")
+
+// **Rod Not Damaged Error**
+// Returned when repairing a rod that has no wear to repair.
+var ErrRodNotDamaged = errors.New("that rod isn't damaged")
+
+// **Upgrade Material Structure**
+// Items consumed by the first level of an upgrade.
+type UpgradeMaterial struct {
+    ItemID   string // Catalog ID
+    Quantity int
+}
+
+// **Rod Upgrade Structure**
+// A stat players can improve on a rod. Every level costs as much again as the
+// first, so level 3 costs three times the price and materials of level 1.
+type RodUpgrade struct {
+    Name      string            // Shown to players
+    MaxLevel  int               // Highest level a rod can reach
+    Price     int               // Price of the first level
+    Materials []UpgradeMaterial // Materials used up by the first level
+    apply     func(stats *RodStats, level int)
+}
+
+// **Rod Upgrades**
+// Upgradeable stats keyed by the `stat` of an `upgradeRod` request.
+var rodUpgrades = map[string]RodUpgrade{
+    "reelSpeed": {
+        Name:      "reel speed",
+        MaxLevel:  5,
+        Price:     150,
+        Materials: []UpgradeMaterial{{ItemID: "commonfish", Quantity: 5}},
+        apply:     func(stats *RodStats, level int) { stats.ReelSpeed *= 1 + 0.1*float64(level) },
+    },
+    "luck": {
+        Name:      "luck",
+        MaxLevel:  5,
+        Price:     250,
+        Materials: []UpgradeMaterial{{ItemID: "redfish", Quantity: 2}},
+        apply:     func(stats *RodStats, level int) { stats.Luck += 0.25 * float64(level) },
+    },
+}
+
+// **Missing Materials Error**
+// Returned when a player lacks the materials for an upgrade.
+type MissingMaterialsError struct {
+    Missing []string // e.g. "3 x Commonfish"
+}
+
+func (e *MissingMaterialsError) Error() string {
+    return fmt.Sprintf("missing materials: %s", strings.Join(e.Missing, ", "))
+}
+
+// **Upgraded**
+// The stats with the rod's upgrades applied.
+func (r RodStats) upgraded(rod *RodState) RodStats {
+    if rod == nil {
+        return r
+    }
+    for stat, level := range rod.Upgrades {
+        if upgrade, ok := rodUpgrades[stat]; ok && level > 0 {
+            upgrade.apply(&r, level)
+        }
+    }
+    return r
+}
+
+// **Repair Price**
+// What restoring the rod's durability costs: its share of the catalog value,
+// scaled by how much durability is missing. 0 if there's nothing to repair.
+func repairPrice(entry CatalogItem, rod *RodState) int {
+    if rod == nil || rod.MaxDurability == 0 || rod.Durability >= rod.MaxDurability {
+        return 0
+    }
+    missing := float64(rod.MaxDurability-rod.Durability) / float64(rod.MaxDurability)
+    return int(math.Max(1, math.Ceil(float64(entry.Value)*rodRepairRate*missing)))
+}
+
+// **Upgrade Cost**
+// The price and materials of taking the stat to `level`.
+func (u RodUpgrade) cost(level int) (int, []UpgradeMaterial) {
+    materials := make([]UpgradeMaterial, len(u.Materials))
+    for i, material := range u.Materials {
+        materials[i] = UpgradeMaterial{ItemID: material.ItemID, Quantity: material.Quantity * level}
+    }
+    return u.Price * level, materials
+}
+
+// **Find Rod**
+// Index of the rod with the UID in the player's inventory, or -1 if they
+// have no such rod. Caller must hold `mu`.
+func findRod(player *Player, uid string) int {
+    i := findItem(player.Inventory, uid)
+    if i < 0 || player.Inventory[i].Rod == nil {
+        return -1
+    }
+    return i
+}
+
+// **Repair Rod**
+// Restores the rod at inventory index `i` to full durability and debits the
+// price. Returns the repaired rod and the price. Caller must hold `mu`.
+func repairRod(player *Player, i int) (Item, int, error) {
+    entry, err := lookupCatalogItem(player.Inventory[i].ID, "")
+    if err != nil {
+        return Item{}, 0, err
+    }
+    price := repairPrice(entry, player.Inventory[i].Rod)
+    if price == 0 {
+        return Item{}, 0, ErrRodNotDamaged
+    }
+    if player.Balance < price {
+        return Item{}, 0, &InsufficientFundsError{Required: price, Balance: player.Balance}
+    }
+
+    after := copyPlayer(player)
+    rod := after.Inventory[i].Rod.clone()
+    rod.Durability = rod.MaxDurability
+    after.Inventory[i].Rod = rod
+    after.Balance -= price
+
+    if err := commitTrade(player, after, []Item{after.Inventory[i]}); err != nil {
+        return Item{}, 0, err
+    }
+    return after.Inventory[i], price, nil
+}
+
+// **Upgrade Rod**
+// Raises the stat of the rod at inventory index `i` by a level, debiting the
+// price and using up the materials, cheapest first. Returns the upgraded rod
+// and the price. Caller must hold `mu`.
+func upgradeRod(player *Player, i int, stat string) (Item, int, error) {
+    upgrade := rodUpgrades[stat]
+    level := player.Inventory[i].Rod.Upgrades[stat] + 1
+    if level > upgrade.MaxLevel {
+        return Item{}, 0, ErrRodMaxed
+    }
+    price, materials := upgrade.cost(level)
+
+    var missing []string
+    for _, material := range materials {
+        owned := 0
+        for _, invItem := range player.Inventory {
+            if invItem.ID == material.ItemID {
+                owned += invItem.Quantity
+            }
+        }
+        if owned < material.Quantity {
+            name := material.ItemID
+            if entry, ok := itemCatalog[material.ItemID]; ok {
+                name = entry.Name
+            }
+            missing = append(missing, fmt.Sprintf("%d x %s", material.Quantity-owned, name))
+        }
+    }
+    if len(missing) > 0 {
+        return Item{}, 0, &MissingMaterialsError{Missing: missing}
+    }
+    if player.Balance < price {
+        return Item{}, 0, &InsufficientFundsError{Required: price, Balance: player.Balance}
+    }
+
+    after := copyPlayer(player)
+    rod := after.Inventory[i].Rod.clone()
+    if rod.Upgrades == nil {
+        rod.Upgrades = make(map[string]int)
+    }
+    rod.Upgrades[stat] = level
+    after.Inventory[i].Rod = rod
+    after.Balance -= price
+    upgraded := after.Inventory[i]
+
+    changed := []Item{upgraded}
+    for _, material := range materials {
+        inventory, rows, _, err := takeItems(after.Inventory, "", material.ItemID, material.Quantity)
+        if err != nil {
+            return Item{}, 0, err
+        }
+        after.Inventory = inventory
+        changed = append(changed, rows...)
+    }
+
+    if err := commitTrade(player, after, changed); err != nil {
+        return Item{}, 0, err
+    }
+    return upgraded, price, nil
+}
+
+// **Send Rod Service Error (Locked)**
+// Answers a failed repair or upgrade with the matching error code. Caller must hold `mu`.
+func sendRodServiceErrorLocked(req Request, err error) {
+    var fundsErr *InsufficientFundsError
+    var materialsErr *MissingMaterialsError
+    var tradeErr *TradeFailedError
+    code := ErrCodeInvalidItem
+    switch {
+    case errors.As(err, &fundsErr):
+        code = ErrCodeInsufficientFunds
+    case errors.As(err, &materialsErr):
+        code = ErrCodeMissingMaterials
+    case errors.As(err, &tradeErr):
+        code = ErrCodeTradeFailed
+    case errors.Is(err, ErrRodMaxed):
+        code = ErrCodeMaxLevel
+    case errors.Is(err, ErrRodNotDamaged):
+        code = ErrCodeNotDamaged
+    }
+    WarningLogger.Printf("%s by player %s rejected: %v", req.Type, req.Player.ID, err)
+    sendError(req.Player, req.RequestID, code, err.Error())
+}
+
+// **Handle Repair Rod**
+// Repairs one of the player's rods for the server's price.
+func handleRepairRod(req Request, repair *RepairRodRequest) {
+    player := req.Player
+    mu.Lock()
+    defer mu.Unlock()
+
+    if replayTradeLocked(req) {
+        return
+    }
+
+    i := findRod(player, repair.UID)
+    if i < 0 {
+        sendError(player, req.RequestID, ErrCodeNotOwned, "You don't have that rod")
+        return
+    }
+    rod, price, err := repairRod(player, i)
+    if err != nil {
+        sendRodServiceErrorLocked(req, err)
+        return
+    }
+    DebugLogger.Printf("Player %s repaired their %s for %d", player.ID, rod.Name, price)
+
+    repairMessage := Message{
+        Type:   "rodEvent",
+        Player: player,
+        Data: RodEventData{
+            Event:    "rodRepaired",
+            PlayerID: player.ID,
+            Item:     rod,
+            Price:    price,
+        },
+    }
+    rememberReplyLocked(req, repairMessage)
+    sendTradeResultLocked(req, repairMessage)
+}
+
+// **Handle Upgrade Rod**
+// Upgrades a stat of one of the player's rods by a level.
+func handleUpgradeRod(req Request, upgrade *UpgradeRodRequest) {
+    player := req.Player
+    mu.Lock()
+    defer mu.Unlock()
+
+    if replayTradeLocked(req) {
+        return
+    }
+
+    i := findRod(player, upgrade.UID)
+    if i < 0 {
+        sendError(player, req.RequestID, ErrCodeNotOwned, "You don't have that rod")
+        return
+    }
+    rod, price, err := upgradeRod(player, i, upgrade.Stat)
+    if err != nil {
+        sendRodServiceErrorLocked(req, err)
+        return
+    }
+    DebugLogger.Printf("Player %s upgraded the %s of their %s to level %d for %d", player.ID, upgrade.Stat, rod.Name, rod.Rod.Upgrades[upgrade.Stat], price)
+
+    upgradeMessage := Message{
+        Type:   "rodEvent",
+        Player: player,
+        Data: RodEventData{
+            Event:    "rodUpgraded",
+            PlayerID: player.ID,
+            Item:     rod,
+            Price:    price,
+            Stat:     upgrade.Stat,
+        },
+    }
+    rememberReplyLocked(req, upgradeMessage)
+    sendTradeResultLocked(req, upgradeMessage)
+}
@@ -1,4 +1,7 @@
 // /js/entities/Player.js
+// Held keys repeat faster than the server accepts moves, so moves are spaced out
+const MOVE_INTERVAL_MS = 100;
+
 class Player {
   constructor(game, data = {}) {
     this.game = game;
@@ -11,6 +14,10 @@ class Player {
     this.playerFacingWater = data.facingWater || false;
     this.balance = data.balance || 0;
     this.inventory = data.inventory || [];
+    this.idle = data.idle || false;
+    this.afk = data.afk || false;
+    this.equippedRod = data.equippedRod || "";
+    this.lastMoveAt = 0;
   }
 
   generateUniqueId() {
@@ -36,21 +43,30 @@ class Player {
     this.renderY += (this.y - this.renderY) * t;
   }
 
+  // Snapshots only carry the fields that changed, and balance only ever
+  // comes with our own player, so leave anything missing as it is
   updateData(data) {
-    this.x = data.x;
-    this.y = data.y;
-    this.direction = data.direction;
-    this.playerFacingWater = data.facingWater;
-    this.balance = data.balance;
+    if (data.x !== undefined) this.x = data.x;
+    if (data.y !== undefined) this.y = data.y;
+    if (data.direction !== undefined) this.direction = data.direction;
+    if (data.facingWater !== undefined) {
+      this.playerFacingWater = data.facingWater;
+    }
+    if (data.balance !== undefined) this.balance = data.balance;
+    if (data.idle !== undefined) this.idle = data.idle;
+    if (data.afk !== undefined) this.afk = data.afk;
+    if (data.equippedRod !== undefined) this.equippedRod = data.equippedRod;
   }
 
   move(direction) {
     // Send movement to server via NetworkManager
+    const now = performance.now();
+    if (now - this.lastMoveAt < MOVE_INTERVAL_MS) return;
     if (!this.game.fishingMechanic.isFishing) {
+      this.lastMoveAt = now;
       const moveMessage = {
         type: "move",
-        player: { id: this.id },
-        data: direction,
+        data: { direction: direction },
       };
       this.game.networkManager.sendMessage(moveMessage);
     }
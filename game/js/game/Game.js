@@ -139,7 +139,11 @@ class Game {
 
   updateGameState(data) {
     // Update game map and players
-    this.map.setMap(data.gameMap);
+    if (data.tiles) {
+      this.map.setTileGrid(data.tiles);
+    } else {
+      this.map.setMap(data.gameMap);
+    }
 
     // Update the list of players
     this.players = data.players.map(
@@ -151,15 +155,24 @@ class Game {
     const player = this.players.find((p) => p.id === this.localPlayer.id);
     if (player) {
       this.localPlayer = player;
+      // Public player state has no balance, the server sends ours alongside
+      this.localPlayer.balance = data.balance;
+      this.inventoryCapacity = data.inventoryCapacity;
       this.updateInventory(data.inventory);
+      this.updatePlayerBalance();
     }
   }
 
   updatePlayer(playerData) {
     const existingPlayer = this.players.find((p) => p.id === playerData.id);
     if (existingPlayer) {
+      const equippedRod = existingPlayer.equippedRod;
       existingPlayer.updateData(playerData);
       this.updatePlayerBalance();
+      // The inventory shows which rod is in hand
+      if (existingPlayer === this.localPlayer && existingPlayer.equippedRod !== equippedRod) {
+        this.uiManager.inventoryUI.renderInventory();
+      }
     } else {
       this.addNewPlayer(playerData);
       this.updatePlayerBalance();
@@ -11,6 +11,29 @@ class Map {
     this.updateVisibility();
   }
 
+  // Expands the compact tile grid the server sends into the same structure
+  // as a full map. `types` is base64 under JSON and binary under MessagePack
+  setTileGrid(tiles) {
+    let types = tiles.types;
+    if (typeof types === "string") {
+      types = Uint8Array.from(atob(types), (c) => c.charCodeAt(0));
+    }
+    const gameMap = [];
+    for (let y = 0; y < tiles.height; y++) {
+      const row = [];
+      for (let x = 0; x < tiles.width; x++) {
+        row.push({
+          x: x,
+          y: y,
+          type: types[y * tiles.width + x],
+          visibility: "unexplored",
+        });
+      }
+      gameMap.push(row);
+    }
+    this.setMap(gameMap);
+  }
+
   updateVisibility() {
     if (!this.gameMap) return;
     const tileX = this.game.localPlayer.x;
@@ -3,20 +3,14 @@ class FishingMechanic {
   constructor(game) {
     this.game = game;
     this.isFishing = false;
+    this.isReeling = false;
   }
 
   startFishing() {
     if (!this.isFishing & this.game.localPlayer.playerFacingWater) {
       // Send a message to the server to start fishing
       this.isFishing = true;
-      const actionMessage = {
-        type: "action",
-        player: { id: this.game.localPlayer.id },
-        data: {
-          actionType: "fish",
-          direction: this.game.localPlayer.direction,
-        },
-      };
+      const actionMessage = { type: "fish" };
       this.game.networkManager.sendMessage(actionMessage);
     }
   }
@@ -26,14 +20,24 @@ class FishingMechanic {
       switch (data.event) {
         case "start":
           // Start the catch window in the UI
-          this.game.uiManager.fishingUI.startCatchWindow();
+          this.game.uiManager.fishingUI.startCatchWindow(data.catchWindowMs);
+          break;
+        case "hooked":
+          // The fight is on, the server decides how it goes
+          this.isReeling = false;
+          this.game.uiManager.fishingUI.startReeling(data.reel);
+          break;
+        case "reel":
+          // Line tension and progress from the server
+          this.game.uiManager.fishingUI.updateReel(data.line);
           break;
         case "catch":
           // Player successfully caught a fish
-          this.game.uiManager.fishingUI.showFishToPlayer(data.fish);
+          this.game.uiManager.fishingUI.showFishToPlayer(data);
           break;
         case "fail":
           // Player failed to catch the fish
+          console.log("Fishing failed:", data.reason);
           this.isFishing = false;
           this.game.uiManager.fishingUI.resetFishingUI();
           break;
@@ -46,27 +50,28 @@ class FishingMechanic {
   attemptCatch() {
     // Send a catch attempt message to the server
     if (this.game.uiManager.fishingUI.isVisible) {
-      const catchMessage = {
-        type: "catchAttempt",
-        player: { id: this.game.localPlayer.id },
-      };
+      const catchMessage = { type: "catchAttempt" };
       this.game.networkManager.sendMessage(catchMessage);
     }
   }
 
+  setReeling(reeling) {
+    // Only changes are sent, the server holds the reel until told otherwise
+    if (this.isReeling !== reeling) {
+      this.isReeling = reeling;
+      const reelMessage = { type: "reel", data: { reeling } };
+      this.game.networkManager.sendMessage(reelMessage);
+    }
+  }
+
   failCatch() {
-    // Inform the server that the catch failed
-    const failMessage = {
-      type: "catchFail",
-      player: { id: this.game.localPlayer.id },
-    };
-    this.game.networkManager.sendMessage(failMessage);
-    // Reset the UI
+    // The server ends the catch window on its own, so just reset the UI
     this.game.uiManager.fishingUI.resetFishingUI();
   }
 
   resetState() {
     this.isFishing = false;
+    this.isReeling = false;
   }
 }
 
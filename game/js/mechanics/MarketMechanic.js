@@ -3,6 +3,23 @@
 class MarketMechanic {
   constructor(game) {
     this.game = game;
+    this.shopItems = [];
+  }
+
+  /** Asks the server which items this player can see in the shop
+   */
+  requestCatalog() {
+    const catalogMessage = { type: "shopCatalog" };
+    this.game.networkManager.sendMessage(catalogMessage);
+  }
+
+  /** Stores the shop items sent by the server and re-renders the buy tab
+   *
+   * @param {*} items
+   */
+  updateShopCatalog(items) {
+    this.shopItems = items || [];
+    this.game.uiManager.marketUI.renderBuyItems();
   }
   /** Sends a buyItem message to the server containing the Item(s) to be bought
    *
@@ -10,22 +27,20 @@ class MarketMechanic {
    */
   startBuy(item) {
     const buyMessage = {
-      type: "action",
-      player: { id: this.game.localPlayer.id },
-      data: { actionType: "buyItem", item: item },
+      type: "buyItem",
+      data: { itemId: item.id, quantity: item.quantity },
     };
     this.game.networkManager.sendMessage(buyMessage);
   }
 
-  /** Sends a sellItem message to the server containing the Item(s) to be sold
+  /** Sends a sellItem message to the server for that exact inventory item
    *
    * @param {*} item
    */
   startSell(item) {
     const sellMessage = {
-      type: "action",
-      player: { id: this.game.localPlayer.id },
-      data: { actionType: "sellItem", item: item },
+      type: "sellItem",
+      data: { uid: item.uid, quantity: item.quantity },
     };
     this.game.networkManager.sendMessage(sellMessage);
   }
@@ -37,7 +52,7 @@ class MarketMechanic {
           this.game.uiManager.marketUI.renderSellItems();
           break;
         case "itemBought":
-          this.game.uiManager.marketUI.renderBuyItems();
+          this.requestCatalog();
           break;
         default:
           console.warn("Unknown Market event:", data.event);
@@ -1,12 +1,145 @@
 // /js/network/NetworkManager.js
+import * as msgpack from "./msgpack.js";
+
+const SERVER_URL = "http://localhost:8081";
+// Newest protocol version this client speaks
+const PROTOCOL_VERSION = 2;
+// Wire encodings we speak, most preferred first
+const CODECS = ["msgpack", "json"];
+// Requests that spend or earn money, resent after a resumed reconnect until answered
+const TRADE_TYPES = ["sellItem", "buyItem", "repairRod", "upgradeRod"];
+
+// An error from /login or /register; `retry` is set when trying again later may help
+async function authError(prefix, response) {
+  let reason = response.statusText;
+  try {
+    reason = (await response.json()).error || reason;
+  } catch {
+    // Not a JSON body, the status text will do
+  }
+  const error = new Error(`${prefix}: ${reason}`);
+  error.retry = response.status === 429 || response.status >= 500;
+  return error;
+}
+
 class NetworkManager {
   constructor(game) {
     this.game = game;
     this.socket = null;
+    this.token = null;
+    this.sessionReplaced = false;
+    this.kicked = false;
+    this.resumeToken = null;
+    // Agreed in the `session` message; until then everything is JSON
+    this.codec = "json";
+    // Random per page load, so IDs never collide with ones the server
+    // remembers from before a reload
+    this.requestIdPrefix = Math.random().toString(36).slice(2, 10);
+    this.nextRequestId = 1;
+    // Trades sent but not answered yet, resent with the same requestId when
+    // the session is resumed; the session won't apply a requestId twice
+    this.pendingTrades = new Map();
+  }
+
+  // The token from the last login, so a reload doesn't need the password
+  // again. Only the token is kept, never the password.
+  loadToken() {
+    const token = localStorage.getItem("PlayerToken");
+    const expiresAt = Number(localStorage.getItem("PlayerTokenExpiresAt"));
+    if (token && expiresAt * 1000 > Date.now()) {
+      return token;
+    }
+    this.forgetToken();
+    return null;
+  }
+
+  forgetToken() {
+    this.token = null;
+    localStorage.removeItem("PlayerToken");
+    localStorage.removeItem("PlayerTokenExpiresAt");
   }
 
-  connect() {
-    this.socket = new WebSocket("ws://localhost:8081/ws");
+  // Browsers from before only tokens were kept have a generated password
+  // stored, which is used one last time instead of asking
+  askPassword() {
+    const stored = localStorage.getItem("PlayerPassword");
+    if (stored) {
+      return stored;
+    }
+    return prompt(
+      `Password for ${this.game.localPlayer.id} (new players choose one, at least 8 characters)`
+    );
+  }
+
+  postCredentials(path, password) {
+    return fetch(SERVER_URL + path, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({
+        playerId: this.game.localPlayer.id,
+        password: password,
+      }),
+    });
+  }
+
+  // Logs in, registering the account first if it doesn't exist yet
+  async login() {
+    const password = this.askPassword();
+    if (password === null) {
+      const error = new Error("A password is needed to play");
+      error.retry = false;
+      throw error;
+    }
+    let response = await this.postCredentials("/login", password);
+    if (response.status === 401) {
+      const registered = await this.postCredentials("/register", password);
+      if (!registered.ok && registered.status !== 409) {
+        const error = await authError("Registration failed", registered);
+        // A password the server won't take
+        error.askAgain = registered.status === 400;
+        throw error;
+      }
+      response = await this.postCredentials("/login", password);
+      if (response.status === 401) {
+        localStorage.removeItem("PlayerPassword");
+        // Taken by an account or a saved player we can't log in as
+        const error = await authError("Can't log in", registered.ok ? response : registered);
+        error.askAgain = true;
+        throw error;
+      }
+    }
+    if (!response.ok) {
+      throw await authError("Login failed", response);
+    }
+    localStorage.removeItem("PlayerPassword");
+    const session = await response.json();
+    this.token = session.token;
+    localStorage.setItem("PlayerToken", session.token);
+    localStorage.setItem("PlayerTokenExpiresAt", session.expiresAt);
+  }
+
+  async connect() {
+    this.token = this.token || this.loadToken();
+    if (!this.token) {
+      try {
+        await this.login();
+      } catch (error) {
+        console.error(error);
+        if (error.retry === false) {
+          // The server would give the same answer again
+          alert(error.message);
+          if (error.askAgain) {
+            this.connect();
+          }
+          return;
+        }
+        setTimeout(() => this.connect(), 5000);
+        return;
+      }
+    }
+    this.socket = new WebSocket(SERVER_URL.replace(/^http/, "ws") + "/ws");
+    this.socket.binaryType = "arraybuffer";
+    this.codec = "json";
 
     // Attach event listeners
     this.socket.addEventListener("open", this.onSocketOpen.bind(this));
@@ -21,23 +154,42 @@ class NetworkManager {
     // Send a 'join' message to the server
     const joinMessage = {
       type: "join",
-      player: {
-        id: this.game.localPlayer.id,
-        x: this.game.localPlayer.x,
-        y: this.game.localPlayer.y,
-        direction: this.game.localPlayer.direction,
+      data: {
+        version: PROTOCOL_VERSION,
+        playerId: this.game.localPlayer.id,
+        codecs: CODECS,
       },
     };
+    if (this.token) {
+      joinMessage.data.token = this.token;
+    }
+    if (this.resumeToken) {
+      // Lets the server replay what we missed instead of sending a full gameState
+      joinMessage.data.resumeToken = this.resumeToken;
+    }
     this.sendMessage(joinMessage);
   }
 
   onSocketMessage(event) {
-    const message = JSON.parse(event.data);
+    // MessagePack arrives in binary frames, JSON in text frames
+    const message =
+      event.data instanceof ArrayBuffer
+        ? msgpack.decode(event.data)
+        : JSON.parse(event.data);
     this.handleServerMessage(message);
   }
 
   onSocketClose(event) {
-    console.log("Disconnected from the WebSocket server");
+    console.log("Disconnected from the WebSocket server", event.reason);
+    if (this.sessionReplaced) {
+      // Reconnecting would just kick the newer session in turn
+      console.log("Session was replaced by another login, not reconnecting");
+      return;
+    }
+    if (this.kicked) {
+      console.log("Kicked by the server, not reconnecting");
+      return;
+    }
     // Attempt to reconnect after a delay
     setTimeout(() => {
       console.log("Attempting to reconnect...");
@@ -50,19 +202,24 @@ class NetworkManager {
   }
 
   handleServerMessage(message) {
+    if (message.requestId) {
+      this.pendingTrades.delete(message.requestId);
+    }
     switch (message.type) {
       case "gameState":
         this.game.updateGameState(message.data);
-        this.game.updatePlayer(message.player);
         break;
       case "playerUpdate":
         this.game.updatePlayer(message.player);
         break;
+      case "snapshot":
+        this.handleSnapshot(message.data);
+        break;
       case "newPlayer":
         this.game.addNewPlayer(message.player);
         break;
       case "playerLeft":
-        this.game.removePlayer(message.data);
+        this.game.removePlayer(message.data.playerId);
         break;
       case "fishingEvent":
         this.game.fishingMechanic.handleFishingEvent(message.data);
@@ -73,6 +230,40 @@ class NetworkManager {
       case "buyEvent":
         this.game.marketMechanic.handleMarketEvent(message.data);
         break;
+      case "rodEvent":
+        this.game.uiManager.inventoryUI.handleRodEvent(message.data);
+        break;
+      case "shopCatalog":
+        this.game.marketMechanic.updateShopCatalog(message.data.items);
+        break;
+      case "session":
+        this.resumeToken = message.data.resumeToken;
+        this.codec = message.data.codec;
+        if (message.data.resumed) {
+          console.log("Resumed previous session");
+          // Replies to anything the server handled were replayed before this,
+          // so whatever is still pending never arrived
+          this.pendingTrades.forEach((trade) => this.sendMessage(trade));
+        } else {
+          // A new session doesn't remember what it already applied, so a
+          // resent trade could go through twice; the game state that follows
+          // shows whether it did
+          this.pendingTrades.clear();
+        }
+        break;
+      case "ack":
+        break;
+      case "sessionReplaced":
+        this.sessionReplaced = true;
+        alert(message.data.message);
+        break;
+      case "kicked":
+        this.kicked = true;
+        alert(message.data.message);
+        break;
+      case "error":
+        this.handleServerError(message.data);
+        break;
       case "inventoryUpdate":
         this.game.updateInventory(message.data);
         break;
@@ -81,8 +272,50 @@ class NetworkManager {
     }
   }
 
+  // Changes to the players in view from one server tick
+  handleSnapshot(snapshot) {
+    const entered = snapshot.entered || [];
+    if (snapshot.full) {
+      // Everyone in view is listed, so anyone else is out of view
+      const present = new Set(entered.map((p) => p.id));
+      this.game.players
+        .filter((p) => !present.has(p.id))
+        .forEach((p) => this.game.removePlayer(p.id));
+    }
+    entered.forEach((playerData) => this.game.updatePlayer(playerData));
+    snapshot.players.forEach((playerData) => this.game.updatePlayer(playerData));
+    (snapshot.left || []).forEach((playerId) => this.game.removePlayer(playerId));
+  }
+
+  handleServerError(error) {
+    console.warn(
+      `Server error (${error.code}) for request ${error.requestId}:`,
+      error.message
+    );
+    if (error.code === "unauthorized") {
+      // Expired or from another server, the reconnect logs in again
+      this.forgetToken();
+    }
+    if (error.code === "cantFishHere" || error.code === "rodBroken") {
+      this.game.fishingMechanic.resetState();
+    }
+  }
+
   sendMessage(message) {
-    this.socket.send(JSON.stringify(message));
+    if (message.type !== "join" && !message.requestId) {
+      message.requestId = `${this.requestIdPrefix}-${this.nextRequestId++}`;
+    }
+    if (TRADE_TYPES.includes(message.type)) {
+      this.pendingTrades.set(message.requestId, message);
+    }
+    if (!this.socket || this.socket.readyState !== WebSocket.OPEN) {
+      return;
+    }
+    if (this.codec === "msgpack") {
+      this.socket.send(msgpack.encode(message));
+    } else {
+      this.socket.send(JSON.stringify(message));
+    }
   }
 }
 
@@ -0,0 +1,242 @@
+// /js/network/msgpack.js
+// Minimal MessagePack encoder/decoder for the game protocol. Supports nil,
+// booleans, numbers, strings, binary (as Uint8Array), arrays and maps (as
+// plain objects). Extension types are not used by the server. Integers are
+// written in their smallest form, as the server writes them, and only
+// numbers that aren't safe integers as float64.
+
+const textEncoder = new TextEncoder();
+const textDecoder = new TextDecoder();
+
+/** Encodes a value as MessagePack
+ *
+ * @param {*} value
+ * @returns {Uint8Array}
+ */
+export function encode(value) {
+  const bytes = [];
+  write(bytes, value);
+  return Uint8Array.from(bytes);
+}
+
+function writeUint(bytes, value, size) {
+  for (let shift = (size - 1) * 8; shift >= 0; shift -= 8) {
+    bytes.push(Math.floor(value / 2 ** shift) & 0xff);
+  }
+}
+
+function writeLength(bytes, length, fix, fixMax, codes) {
+  if (fix !== null && length <= fixMax) {
+    bytes.push(fix | length);
+  } else if (codes[0] !== null && length <= 0xff) {
+    bytes.push(codes[0]);
+    writeUint(bytes, length, 1);
+  } else if (length <= 0xffff) {
+    bytes.push(codes[1]);
+    writeUint(bytes, length, 2);
+  } else {
+    bytes.push(codes[2]);
+    writeUint(bytes, length, 4);
+  }
+}
+
+function write(bytes, value) {
+  if (value === null || value === undefined) {
+    bytes.push(0xc0);
+  } else if (value === false) {
+    bytes.push(0xc2);
+  } else if (value === true) {
+    bytes.push(0xc3);
+  } else if (typeof value === "number") {
+    writeNumber(bytes, value);
+  } else if (typeof value === "string") {
+    const encoded = textEncoder.encode(value);
+    writeLength(bytes, encoded.length, 0xa0, 31, [0xd9, 0xda, 0xdb]);
+    encoded.forEach((b) => bytes.push(b));
+  } else if (value instanceof Uint8Array) {
+    writeLength(bytes, value.length, null, 0, [0xc4, 0xc5, 0xc6]);
+    value.forEach((b) => bytes.push(b));
+  } else if (Array.isArray(value)) {
+    writeLength(bytes, value.length, 0x90, 15, [null, 0xdc, 0xdd]);
+    value.forEach((item) => write(bytes, item));
+  } else if (typeof value === "object") {
+    const keys = Object.keys(value).filter((key) => value[key] !== undefined);
+    writeLength(bytes, keys.length, 0x80, 15, [null, 0xde, 0xdf]);
+    keys.forEach((key) => {
+      write(bytes, key);
+      write(bytes, value[key]);
+    });
+  } else {
+    throw new Error(`msgpack: can't encode ${typeof value}`);
+  }
+}
+
+function writeNumber(bytes, value) {
+  if (!Number.isSafeInteger(value)) {
+    writeFloat(bytes, value);
+  } else if (value >= 0) {
+    if (value <= 0x7f) {
+      bytes.push(value);
+    } else if (value <= 0xff) {
+      bytes.push(0xcc);
+      writeUint(bytes, value, 1);
+    } else if (value <= 0xffff) {
+      bytes.push(0xcd);
+      writeUint(bytes, value, 2);
+    } else if (value <= 0xffffffff) {
+      bytes.push(0xce);
+      writeUint(bytes, value, 4);
+    } else {
+      bytes.push(0xcf);
+      writeUint(bytes, value, 8);
+    }
+  } else if (value >= -32) {
+    bytes.push(value & 0xff);
+  } else if (value >= -0x80) {
+    bytes.push(0xd0);
+    writeUint(bytes, value & 0xff, 1);
+  } else if (value >= -0x8000) {
+    bytes.push(0xd1);
+    writeUint(bytes, value & 0xffff, 2);
+  } else if (value >= -0x80000000) {
+    bytes.push(0xd2);
+    writeUint(bytes, value >>> 0, 4);
+  } else {
+    // Two's complement needs all 64 bits, which only BigInt has
+    const view = new DataView(new ArrayBuffer(8));
+    view.setBigInt64(0, BigInt(value));
+    bytes.push(0xd3);
+    new Uint8Array(view.buffer).forEach((b) => bytes.push(b));
+  }
+}
+
+function writeFloat(bytes, value) {
+  const view = new DataView(new ArrayBuffer(8));
+  view.setFloat64(0, value);
+  bytes.push(0xcb);
+  new Uint8Array(view.buffer).forEach((b) => bytes.push(b));
+}
+
+/** Decodes a MessagePack message
+ *
+ * @param {ArrayBuffer|Uint8Array} buffer
+ * @returns {*}
+ */
+export function decode(buffer) {
+  const data = buffer instanceof Uint8Array ? buffer : new Uint8Array(buffer);
+  const reader = {
+    data: data,
+    view: new DataView(data.buffer, data.byteOffset, data.byteLength),
+    pos: 0,
+  };
+  const value = read(reader);
+  if (reader.pos !== data.length) {
+    throw new Error("msgpack: unexpected data after message");
+  }
+  return value;
+}
+
+function read(r) {
+  const code = r.data[r.pos++];
+  if (code === undefined) {
+    throw new Error("msgpack: unexpected end of data");
+  }
+  if (code <= 0x7f) return code;
+  if (code >= 0xe0) return code - 0x100;
+  if ((code & 0xf0) === 0x80) return readMap(r, code & 0x0f);
+  if ((code & 0xf0) === 0x90) return readArray(r, code & 0x0f);
+  if ((code & 0xe0) === 0xa0) return readString(r, code & 0x1f);
+
+  switch (code) {
+    case 0xc0:
+      return null;
+    case 0xc2:
+      return false;
+    case 0xc3:
+      return true;
+    case 0xc4:
+      return readBinary(r, readUint(r, 1));
+    case 0xc5:
+      return readBinary(r, readUint(r, 2));
+    case 0xc6:
+      return readBinary(r, readUint(r, 4));
+    case 0xca:
+      return advance(r, 4, r.view.getFloat32(r.pos));
+    case 0xcb:
+      return advance(r, 8, r.view.getFloat64(r.pos));
+    case 0xcc:
+      return readUint(r, 1);
+    case 0xcd:
+      return readUint(r, 2);
+    case 0xce:
+      return readUint(r, 4);
+    case 0xcf:
+      return advance(r, 8, Number(r.view.getBigUint64(r.pos)));
+    case 0xd0:
+      return advance(r, 1, r.view.getInt8(r.pos));
+    case 0xd1:
+      return advance(r, 2, r.view.getInt16(r.pos));
+    case 0xd2:
+      return advance(r, 4, r.view.getInt32(r.pos));
+    case 0xd3:
+      return advance(r, 8, Number(r.view.getBigInt64(r.pos)));
+    case 0xd9:
+      return readString(r, readUint(r, 1));
+    case 0xda:
+      return readString(r, readUint(r, 2));
+    case 0xdb:
+      return readString(r, readUint(r, 4));
+    case 0xdc:
+      return readArray(r, readUint(r, 2));
+    case 0xdd:
+      return readArray(r, readUint(r, 4));
+    case 0xde:
+      return readMap(r, readUint(r, 2));
+    case 0xdf:
+      return readMap(r, readUint(r, 4));
+    default:
+      throw new Error(`msgpack: unsupported type 0x${code.toString(16)}`);
+  }
+}
+
+function advance(r, size, value) {
+  r.pos += size;
+  return value;
+}
+
+function readUint(r, size) {
+  let value = 0;
+  for (let i = 0; i < size; i++) {
+    value = value * 256 + r.data[r.pos++];
+  }
+  return value;
+}
+
+function readString(r, length) {
+  const value = textDecoder.decode(r.data.subarray(r.pos, r.pos + length));
+  r.pos += length;
+  return value;
+}
+
+function readBinary(r, length) {
+  const value = r.data.slice(r.pos, r.pos + length);
+  r.pos += length;
+  return value;
+}
+
+function readArray(r, length) {
+  const value = new Array(length);
+  for (let i = 0; i < length; i++) {
+    value[i] = read(r);
+  }
+  return value;
+}
+
+function readMap(r, length) {
+  const value = {};
+  for (let i = 0; i < length; i++) {
+    const key = read(r);
+    value[key] = read(r);
+  }
+  return value;
+}
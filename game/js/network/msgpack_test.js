@@ -0,0 +1,42 @@
+// /js/network/msgpack_test.js
+// Checks the client's MessagePack against what the server's encoder writes.
+// The fixtures are kept up to date by TestMsgpackFixtures in game-server.
+import { assertEquals } from "@std/assert";
+import { decode, encode } from "./msgpack.js";
+import fixtures from "../../../game-server/testdata/msgpack_fixtures.json" with { type: "json" };
+
+function toHex(bytes) {
+  return Array.from(bytes, (b) => b.toString(16).padStart(2, "0")).join("");
+}
+
+function fromHex(hex) {
+  return Uint8Array.from(hex.match(/../g) || [], (pair) => parseInt(pair, 16));
+}
+
+for (const fixture of fixtures) {
+  Deno.test(`decodes ${fixture.name} from the server`, () => {
+    assertEquals(decode(fromHex(fixture.msgpack)), fixture.value);
+  });
+
+  Deno.test(`round trips ${fixture.name}`, () => {
+    assertEquals(decode(encode(fixture.value)), fixture.value);
+  });
+
+  // Whole-number floats come back as integers, so only exact fixtures are
+  // encoded byte for byte like the server does
+  if (fixture.exact) {
+    Deno.test(`encodes ${fixture.name} like the server`, () => {
+      assertEquals(toHex(encode(fixture.value)), fixture.msgpack);
+    });
+  }
+}
+
+Deno.test("round trips a map16", () => {
+  const value = {};
+  for (let i = 0; i < 16; i++) {
+    value[`key${i}`] = i;
+  }
+  const encoded = encode(value);
+  assertEquals(encoded[0], 0xde);
+  assertEquals(decode(encoded), value);
+});
@@ -94,6 +94,8 @@ class Renderer {
         spriteY = 64;
       }
 
+      // Players who dropped and may still resume are drawn faded
+      this.ctx.globalAlpha = player.idle ? 0.4 : 1;
       this.ctx.drawImage(
         this.img,
         spriteX,
@@ -105,9 +107,11 @@ class Renderer {
         32,
         32
       );
+      this.ctx.globalAlpha = 1;
 
       // Draw the fishing prompt if the player is facing water
       this.drawFishingPrompt(player);
+      this.drawAFKLabel(player, playerX, playerY);
       this.drawFishingLine(player);
     });
   }
@@ -133,6 +137,14 @@ class Renderer {
     }
   }
 
+  drawAFKLabel(player, playerX, playerY) {
+    if (!player.afk || player.idle) return;
+    this.ctx.fillStyle = "white";
+    this.ctx.font = "8px 'Press Start 2P'";
+    this.ctx.textAlign = "center";
+    this.ctx.fillText("AFK", playerX + 16, playerY - 4);
+  }
+
   drawFishingLine(player) {
     if (
       player.playerFacingWater &&
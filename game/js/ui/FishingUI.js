@@ -26,7 +26,9 @@ class FishingUI {
       "Waiting for a bite...";
   }
 
-  startCatchWindow() {
+  startCatchWindow(durationMs) {
+    // The server sends the window length for the equipped rod
+    this.catchWindowDuration = durationMs || 3000;
     this.isVisible = true;
     this.isCatchWindowActive = true;
     // Display the "Press space to catch" message
@@ -48,6 +50,51 @@ class FishingUI {
     document.addEventListener("keydown", this.onCatchAttempt);
   }
 
+  startReeling(reel) {
+    this.stopProgressBar();
+    this.fishingPopupContent.innerHTML = `
+      <h2>Hooked! Hold Space to reel, let go before the line snaps!</h2>
+      <div class="reel-meter">
+        <div class="reel-band"></div>
+        <div class="reel-tension"></div>
+      </div>
+    `;
+    // Keep the tension inside the band to bring the fish in
+    const band = this.fishingPopupContent.querySelector(".reel-band");
+    band.style.left = `${reel.bandLow * 100}%`;
+    band.style.width = `${(reel.bandHigh - reel.bandLow) * 100}%`;
+    this.reelTension = this.fishingPopupContent.querySelector(".reel-tension");
+    // The progress bar shows how close the fish is to being landed
+    this.progressBarContainer.style.display = "block";
+    this.progressBar.style.width = "0%";
+    document.addEventListener("keydown", this.onReelDown);
+    document.addEventListener("keyup", this.onReelUp);
+  }
+
+  updateReel(line) {
+    if (!this.reelTension) return;
+    this.reelTension.style.left = `${line.tension * 100}%`;
+    this.progressBar.style.width = `${line.progress * 100}%`;
+  }
+
+  onReelDown = (event) => {
+    if (event.key === " " && !event.repeat) {
+      this.game.fishingMechanic.setReeling(true);
+    }
+  };
+
+  onReelUp = (event) => {
+    if (event.key === " ") {
+      this.game.fishingMechanic.setReeling(false);
+    }
+  };
+
+  stopReeling() {
+    this.reelTension = null;
+    document.removeEventListener("keydown", this.onReelDown);
+    document.removeEventListener("keyup", this.onReelUp);
+  }
+
   updateProgressBar() {
     const elapsedTime = Date.now() - this.catchWindowStartTime;
     const remainingTime = this.catchWindowDuration - elapsedTime;
@@ -102,17 +149,28 @@ class FishingUI {
     this.isVisible = false;
     this.isCatchWindowActive = false;
     document.removeEventListener("keydown", this.onCatchAttempt);
+    this.stopReeling();
   }
 
-  showFishToPlayer(fish) {
-    // Display the fish information
+  showFishToPlayer({ fish, catch: rolled, price, record }) {
+    // Display the fish information, sized and priced by the server
     this.isCatchWindowActive = false;
+    this.stopReeling();
+    const quality =
+      rolled && rolled.quality !== "normal" ? `${rolled.quality} ` : "";
     this.fishingPopupContent.innerHTML = `
-      <h2>Wow, you caught a ${fish.name}!</h2>
+      <h2>Wow, you caught a ${quality}${fish.name}!</h2>
+      ${record ? "<p><strong>New personal record!</strong></p>" : ""}
       <img src="${fish.img}" alt="${fish.name}" width="64" ">
       <div>
         <p><strong>Name:</strong> ${fish.name}</p>
-        <p><strong>Value:</strong> $${fish.value}</p>
+        ${
+          rolled
+            ? `<p><strong>Length:</strong> ${rolled.length} cm</p>
+        <p><strong>Weight:</strong> ${rolled.weight} kg</p>`
+            : ""
+        }
+        <p><strong>Value:</strong> $${price || fish.value}</p>
         <p><strong>Rarity:</strong> ${this.rarityToString(fish)}</p>
       </div>
       <button id="close-button">Close</button>
@@ -1,4 +1,27 @@
 // /js/ui/InventoryUI.js
+const SORT_ORDERS = ["name", "type", "value", "newest"];
+
+// Upgrades the server offers for rods, by the `stat` it expects
+const ROD_UPGRADES = { reelSpeed: "reel", luck: "luck" };
+
+// A stack shows its quantity, a caught fish its weight and quality, a rod its
+// durability and upgrade levels
+export function describeItem(item) {
+  if (item.rod) return `${item.name} ${describeRod(item.rod)}`;
+  if (!item.catch) return `${item.name} x${item.quantity}`;
+  const quality = item.catch.quality !== "normal" ? `${item.catch.quality} ` : "";
+  return `${quality}${item.name} (${item.catch.weight} kg)`;
+}
+
+function describeRod(rod) {
+  const upgrades = Object.entries(rod.upgrades || {})
+    .map(([stat, level]) => ` ${ROD_UPGRADES[stat] || stat}+${level}`)
+    .join("");
+  if (!rod.maxDurability) return upgrades.trim();
+  const durability = rod.durability > 0 ? `${rod.durability}/${rod.maxDurability}` : "broken";
+  return `(${durability})${upgrades}`;
+}
+
 class InventoryUI {
   constructor(game) {
     this.game = game;
@@ -7,16 +30,89 @@ class InventoryUI {
 
   renderInventory() {
     this.inventoryContainer.innerHTML = ""; // Clear existing items
-    if (this.game.localPlayer.inventory) {
-      this.game.localPlayer.inventory.forEach((item) => {
-        const itemElement = document.createElement("div");
-        itemElement.className = "inventory-item";
-        itemElement.innerHTML = `
-          <img src="${item.img}" alt="${item.name}">
-          <span>${item.name} x${item.quantity}</span>
-        `;
-        this.inventoryContainer.appendChild(itemElement);
-      });
+    const inventory = this.game.localPlayer.inventory || [];
+
+    // Slots used and the sort orders the server knows
+    const header = document.createElement("div");
+    header.className = "inventory-header";
+    const capacity = this.game.inventoryCapacity;
+    header.innerHTML = `
+      <span>Inventory ${inventory.length}${capacity ? `/${capacity}` : ""}</span>
+      <div class="inventory-sort">
+        ${SORT_ORDERS.map((by) => `<button data-by="${by}">${by}</button>`).join("")}
+      </div>
+    `;
+    header.querySelectorAll("button").forEach((button) =>
+      button.addEventListener("click", () => this.sortInventory(button.dataset.by))
+    );
+    this.inventoryContainer.appendChild(header);
+
+    inventory.forEach((item) => {
+      const itemElement = document.createElement("div");
+      itemElement.className = "inventory-item";
+      itemElement.innerHTML = `
+        <img src="${item.img}" alt="${item.name}">
+        <span>${describeItem(item)}</span>
+      `;
+      if (item.rod) {
+        itemElement.appendChild(this.createRodActions(item));
+      }
+      this.inventoryContainer.appendChild(itemElement);
+    });
+  }
+
+  sortInventory(by) {
+    // The server keeps the order, the new inventory comes back in an update
+    const sortMessage = { type: "sortInventory", data: { by } };
+    this.game.networkManager.sendMessage(sortMessage);
+  }
+
+  // Equip, repair and upgrade buttons; prices are set by the server and shown once paid
+  createRodActions(item) {
+    const actions = document.createElement("div");
+    actions.className = "rod-actions";
+    // An empty rodId puts the rod away, e.g. to fish bare handed with a broken one
+    const equipped = this.game.localPlayer.equippedRod === item.id;
+    actions.appendChild(
+      this.createRodButton(equipped ? "Unequip" : "Equip", {
+        type: "equipRod",
+        data: { rodId: equipped ? "" : item.id },
+      })
+    );
+    if (item.rod.durability < item.rod.maxDurability) {
+      actions.appendChild(
+        this.createRodButton("Repair", { type: "repairRod", data: { uid: item.uid } })
+      );
+    }
+    Object.entries(ROD_UPGRADES).forEach(([stat, label]) => {
+      actions.appendChild(
+        this.createRodButton(`+${label}`, { type: "upgradeRod", data: { uid: item.uid, stat } })
+      );
+    });
+    return actions;
+  }
+
+  createRodButton(label, message) {
+    const button = document.createElement("button");
+    button.textContent = label;
+    button.addEventListener("click", () => this.game.networkManager.sendMessage(message));
+    return button;
+  }
+
+  handleRodEvent(data) {
+    if (data.playerId !== this.game.localPlayer.id) return;
+    switch (data.event) {
+      case "rodRepaired":
+        console.log(`Repaired ${data.item.name} for ${data.price}`);
+        break;
+      case "rodUpgraded":
+        console.log(`Upgraded ${ROD_UPGRADES[data.stat] || data.stat} of ${data.item.name} for ${data.price}`);
+        break;
+      case "rodBroken":
+        alert(`Your ${data.item.name} broke! Repair it before fishing with it again.`);
+        break;
+      default:
+        console.warn("Unknown rod event:", data.event);
     }
   }
 
@@ -1,4 +1,6 @@
 // /js/ui/MarketUI.js
+import { describeItem } from "./InventoryUI.js";
+
 class MarketUI {
   constructor(game) {
     this.game = game;
@@ -38,6 +40,7 @@ class MarketUI {
     this.marketModal.style.display = "block";
     this.renderSellItems();
     this.renderBuyItems();
+    this.game.marketMechanic.requestCatalog();
     this.updatePlayerMoney();
   }
 
@@ -80,14 +83,19 @@ class MarketUI {
         const itemElement = document.createElement("div");
         itemElement.className = "market-item";
 
+        // Caught fish are priced by the server from their size and quality
         itemElement.innerHTML = `
           <img src="${item.img}" alt="${item.name}">
           <div>
-            <p><strong>${item.name}</strong></p>
-            <p>Quantity: ${item.quantity}</p>
-            <p>Value per unit: $${item.value}</p>
+            <p><strong>${describeItem(item)}</strong></p>
+            ${
+              item.catch
+                ? `<p>Length: ${item.catch.length} cm</p>`
+                : `<p>Quantity: ${item.quantity}</p>
+            <p>Value per unit: $${item.value}</p>`
+            }
           </div>
-          <button class="sell-button">Sell All</button>
+          <button class="sell-button">${item.catch ? "Sell" : "Sell All"}</button>
         `;
 
         // Add event listener for the sell button
@@ -105,39 +113,33 @@ class MarketUI {
     // Clear the container
     this.marketBuyItemsContainer.innerHTML = "";
 
-    // Sample items to buy
-    const itemsForSale = [
-      {
-        type: "Fish",
-        name: "Goldfish",
-        quantity: 1,
-        value: 10,
-        img: "./assets/goldfish.png",
-      },
-      {
-        type: "Fish",
-        name: "Tuna",
-        quantity: 1,
-        value: 20,
-        img: "./assets/tuna.png",
-      },
-    ];
+    // Items come from the server's shopCatalog reply
+    const itemsForSale = this.game.marketMechanic.shopItems;
 
     itemsForSale.forEach((item) => {
       const itemElement = document.createElement("div");
       itemElement.className = "market-item";
 
+      let status = "";
+      if (item.owned) {
+        status = "<p>Owned</p>";
+      } else if (item.locked) {
+        status = `<p>Requires: ${item.requirements.join(", ")}</p>`;
+      }
+
       itemElement.innerHTML = `
         <img src="${item.img}" alt="${item.name}">
         <div>
           <p><strong>${item.name}</strong></p>
           <p>Price: $${item.value}</p>
+          ${status}
         </div>
         <button class="buy-button">Buy</button>
       `;
 
       // Add event listener for the buy button
       const buyButton = itemElement.querySelector(".buy-button");
+      buyButton.disabled = item.locked || item.owned;
       buyButton.addEventListener("click", () => this.buyItem(item));
 
       this.marketBuyItemsContainer.appendChild(itemElement);
@@ -146,22 +148,8 @@ class MarketUI {
 
   buyItem(item) {
     if (this.game.localPlayer.balance >= item.value) {
-      // Deduct the item cost from the player's balance
-      this.game.localPlayer.balance -= item.value;
-
-      // Add the item to the player's inventory
-      const existingItem = this.game.localPlayer.inventory.find(
-        (i) => i.name === item.name
-      );
-      if (existingItem) {
-        existingItem.quantity += item.quantity;
-      } else {
-        this.game.localPlayer.inventory.push({ ...item });
-      }
-
-      // Update the UI
-      this.updatePlayerMoney();
-      this.game.uiManager.inventoryUI.renderInventory();
+      // The server debits the balance and replies with buyEvent
+      this.game.marketMechanic.startBuy({ id: item.id, quantity: 1 });
     } else {
       alert("Not enough money to buy this item!");
     }